@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/services/admin"
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) AdminSearchUsers(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	users, err := r.Admin.SearchUsers(ctx.Request().Context(), accessDetails.GetUserId(), ctx.Params().Get("query"))
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(users)
+}
+
+func (r *Repository) AdminUser(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := utils.IntParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	user, err := r.Admin.GetUser(ctx.Request().Context(), accessDetails.GetUserId(), userId)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(user)
+}
+
+func (r *Repository) AdminImpersonate(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := utils.IntParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	token, err := r.Authorization.CreateImpersonationToken(ctx.Request().Context(), accessDetails.GetUserId(), userId)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	// Token which is not in audit trail should not be used
+	err = r.Admin.Impersonate(ctx.Request().Context(), accessDetails.GetUserId(), userId)
+	if err != nil {
+		revokeErr := r.Authorization.RevokeAccessToken(ctx.Request().Context(), userId, token.ATUuid)
+		if revokeErr != nil {
+			r.log(ctx).Error("cannot revoke impersonation token", logger.Err(revokeErr))
+		}
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(auth.ImpersonationResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   auth.BearerScheme,
+		ExpiresIn:   int(auth.TTLImpersonationToken.Seconds()),
+		ReadOnly:    true,
+	})
+}
+
+func (r *Repository) AdminBlockCard(ctx *rou.Context) {
+	r.adminChangeCardState(ctx, r.Admin.BlockCard)
+}
+
+func (r *Repository) AdminUnblockCard(ctx *rou.Context) {
+	r.adminChangeCardState(ctx, r.Admin.UnblockCard)
+}
+
+func (r *Repository) adminChangeCardState(ctx *rou.Context, change func(ctx context.Context, adminId int, cardId string, reason string) (*admin.Card, error)) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body admin.CardStateChange
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	cardId, err := utils.UUIDParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	card, err := change(ctx.Request().Context(), accessDetails.GetUserId(), cardId, body.Reason)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(card)
+}
+
+func (r *Repository) AdminAdjustBalance(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body admin.BalanceAdjustment
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	cardId, err := utils.UUIDParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	card, err := r.Admin.AdjustBalance(ctx.Request().Context(), accessDetails.GetUserId(), cardId, body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(card)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/Moranilt/billing/services/apikeys"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) APIKeysList(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	keys, err := r.APIKeys.List(ctx.Request().Context(), accessDetails.GetUserId())
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(keys)
+}
+
+func (r *Repository) APIKeysCreate(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body apikeys.APIKeyCreate
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	key, err := r.APIKeys.Create(ctx.Request().Context(), accessDetails.GetUserId(), body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(key)
+}
+
+func (r *Repository) APIKeysRotate(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	keyId, err := utils.UUIDParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	key, err := r.APIKeys.Rotate(ctx.Request().Context(), accessDetails.GetUserId(), keyId)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(key)
+}
+
+func (r *Repository) APIKeysRevoke(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	keyId, err := utils.UUIDParam(ctx, "id")
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.APIKeys.Revoke(ctx.Request().Context(), accessDetails.GetUserId(), keyId)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
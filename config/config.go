@@ -0,0 +1,434 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Every field can be set in YAML file and overridden by environment
+// variable from "env" tag. Fields with "secret" tag can also be read
+// from file which path is set in environment variable with "_FILE" suffix,
+// e.g. BILLING_AUTH_SECRET_FILE=/run/secrets/auth_secret
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Postgres  Postgres  `yaml:"postgres"`
+	Redis     Redis     `yaml:"redis"`
+	Auth      Auth      `yaml:"auth"`
+	Lockout   Lockout   `yaml:"lockout"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	OpenAPI   OpenAPI   `yaml:"openapi"`
+	CORS      CORS      `yaml:"cors"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Notifier  Notifier  `yaml:"notifier"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr" env:"BILLING_SERVER_ADDR" required:"true"`
+	// Time to wait for in-flight requests and background workers
+	// before connections are closed on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"BILLING_SERVER_SHUTDOWN_TIMEOUT" required:"true"`
+	// Time between readiness starts to fail and server stops accepting
+	// requests on shutdown. Included in shutdown timeout
+	DrainDelay time.Duration `yaml:"drain_delay" env:"BILLING_SERVER_DRAIN_DELAY"`
+	// Addresses or CIDRs of load balancers and reverse proxies. Address of
+	// client is taken from X-Forwarded-For or X-Real-IP only when request
+	// comes from one of them. Empty list means that clients connect directly
+	TrustedProxies []string `yaml:"trusted_proxies" env:"BILLING_SERVER_TRUSTED_PROXIES"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" env:"BILLING_POSTGRES_HOST" required:"true"`
+	Port     int    `yaml:"port" env:"BILLING_POSTGRES_PORT" required:"true"`
+	User     string `yaml:"user" env:"BILLING_POSTGRES_USER" required:"true"`
+	Password string `yaml:"password" env:"BILLING_POSTGRES_PASSWORD" secret:"true" required:"true"`
+	Database string `yaml:"database" env:"BILLING_POSTGRES_DATABASE" required:"true"`
+	SSLMode  string `yaml:"sslmode" env:"BILLING_POSTGRES_SSLMODE"`
+}
+
+type Redis struct {
+	Addr     string `yaml:"addr" env:"BILLING_REDIS_ADDR" required:"true"`
+	Password string `yaml:"password" env:"BILLING_REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"BILLING_REDIS_DB"`
+}
+
+type Auth struct {
+	// Secret which is used to sign tokens and hash one-time codes
+	Secret string `yaml:"secret" env:"BILLING_AUTH_SECRET" secret:"true" required:"true"`
+}
+
+type Lockout struct {
+	// Failed attempts of one account after which account is locked
+	MaxAccountFailures int `yaml:"max_account_failures" env:"BILLING_LOCKOUT_MAX_ACCOUNT_FAILURES" required:"true"`
+	// Failed attempts from one IP after which IP is locked
+	MaxIPFailures int `yaml:"max_ip_failures" env:"BILLING_LOCKOUT_MAX_IP_FAILURES" required:"true"`
+	// Failed attempts are counted within this window
+	FailuresWindow time.Duration `yaml:"failures_window" env:"BILLING_LOCKOUT_FAILURES_WINDOW" required:"true"`
+	// Delay after the first failed attempt. Delay is doubled after every
+	// next failed attempt
+	BackoffBase time.Duration `yaml:"backoff_base" env:"BILLING_LOCKOUT_BACKOFF_BASE"`
+	BackoffMax  time.Duration `yaml:"backoff_max" env:"BILLING_LOCKOUT_BACKOFF_MAX"`
+	// Duration of lock of account or IP
+	LockDuration time.Duration `yaml:"lock_duration" env:"BILLING_LOCKOUT_LOCK_DURATION" required:"true"`
+}
+
+type Log struct {
+	// One of debug, info, warning, error
+	Level string `yaml:"level" env:"BILLING_LOG_LEVEL" required:"true"`
+	// Encoding of records: json or console
+	Format string `yaml:"format" env:"BILLING_LOG_FORMAT" required:"true"`
+	// Directory with info.log, warning.log and error.log. It is created
+	// if it does not exist
+	Dir string `yaml:"dir" env:"BILLING_LOG_DIR" required:"true"`
+	// File is rotated when it becomes bigger than this size in megabytes
+	// or when rotate interval is over. Zero disables rotation
+	MaxSizeMB      int           `yaml:"max_size_mb" env:"BILLING_LOG_MAX_SIZE_MB"`
+	RotateInterval time.Duration `yaml:"rotate_interval" env:"BILLING_LOG_ROTATE_INTERVAL"`
+	// Rotated files which are older or exceed number of backups are
+	// removed. Zero keeps all files
+	MaxAge     time.Duration `yaml:"max_age" env:"BILLING_LOG_MAX_AGE"`
+	MaxBackups int           `yaml:"max_backups" env:"BILLING_LOG_MAX_BACKUPS"`
+	// Rotated files are compressed with gzip
+	Compress bool `yaml:"compress" env:"BILLING_LOG_COMPRESS"`
+	// Number of records which wait to be written. When buffer is full
+	// records are dropped or caller waits, depending on overflow: drop or block.
+	// Zero disables buffer: every record is handed to writer directly
+	BufferSize int    `yaml:"buffer_size" env:"BILLING_LOG_BUFFER_SIZE"`
+	Overflow   string `yaml:"overflow" env:"BILLING_LOG_OVERFLOW" required:"true"`
+}
+
+type Tracing struct {
+	// Where spans are sent: none, stdout or otlp
+	Exporter string `yaml:"exporter" env:"BILLING_TRACING_EXPORTER" required:"true"`
+	// URL of OTLP/HTTP endpoint of collector, e.g. http://localhost:4318/v1/traces
+	Endpoint    string `yaml:"endpoint" env:"BILLING_TRACING_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env:"BILLING_TRACING_SERVICE_NAME" required:"true"`
+	// Part of new traces which are exported, from 0 to 1
+	SampleRatio float64 `yaml:"sample_ratio" env:"BILLING_TRACING_SAMPLE_RATIO"`
+}
+
+type OpenAPI struct {
+	// Responses of described routes are checked against specification and
+	// mismatches are logged. Every response is copied, so it is meant for
+	// development and staging
+	CheckResponses bool `yaml:"check_responses" env:"BILLING_OPENAPI_CHECK_RESPONSES"`
+}
+
+type CORS struct {
+	// Origins of web apps which can call API from browser, e.g.
+	// https://app.example.com. "*" allows any origin without credentials.
+	// Empty list disables CORS
+	AllowedOrigins []string `yaml:"allowed_origins" env:"BILLING_CORS_ALLOWED_ORIGINS"`
+	// Browser sends cookies with requests of allowed origins
+	AllowCredentials bool `yaml:"allow_credentials" env:"BILLING_CORS_ALLOW_CREDENTIALS"`
+	// Time for which browser caches result of preflight request
+	MaxAge time.Duration `yaml:"max_age" env:"BILLING_CORS_MAX_AGE"`
+}
+
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"BILLING_RATE_LIMIT_ENABLED"`
+	// Policy of all requests of one IP, by should be ip. It is checked
+	// before authentication, so guessing of tokens and API keys is limited
+	// even though principal is not known yet
+	IP RateLimitPolicy `yaml:"ip"`
+	// Policy of routes which are not listed in routes. Requests of all of
+	// these routes are counted together
+	Default RateLimitPolicy `yaml:"default"`
+	// Policies of routes, every route has its own limit
+	Routes []RateLimitPolicy `yaml:"routes"`
+}
+
+type RateLimitPolicy struct {
+	// Pattern of route without version of API, e.g. /login or
+	// /cards/:id/reveal. It is empty in default policy
+	Route string `yaml:"route"`
+	// Requests are counted by ip or by principal: user, OAuth2 client or
+	// API key. Requests without principal are counted by IP
+	By string `yaml:"by"`
+	// Number of requests which are allowed within window
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+	// Number of requests which can be sent at once, equal to requests
+	// when it is not set
+	Burst int `yaml:"burst"`
+}
+
+type Notifier struct {
+	// Where SMS and notifications are delivered: log or webhook. Log writes
+	// recipient, and message only in development mode
+	Sender string `yaml:"sender" env:"BILLING_NOTIFIER_SENDER" required:"true"`
+	// Log sender does not deliver messages, it is accepted only for
+	// development, and writes messages with codes and tokens
+	Development bool `yaml:"development" env:"BILLING_NOTIFIER_DEVELOPMENT"`
+	// URL of gateway which delivers messages, required for webhook
+	WebhookURL string `yaml:"webhook_url" env:"BILLING_NOTIFIER_WEBHOOK_URL"`
+	// Token which is sent to gateway in Authorization header
+	WebhookToken   string        `yaml:"webhook_token" env:"BILLING_NOTIFIER_WEBHOOK_TOKEN" secret:"true"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" env:"BILLING_NOTIFIER_WEBHOOK_TIMEOUT"`
+}
+
+// Connection string for lib/pq
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(p.Host), p.Port, quoteDSN(p.User), quoteDSN(p.Password), quoteDSN(p.Database), quoteDSN(p.SSLMode))
+}
+
+// Config with values which are used when they are not set in file
+// or environment. Secrets do not have default values
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ShutdownTimeout: time.Second * 30,
+			DrainDelay:      time.Second * 5,
+		},
+		Postgres: Postgres{
+			Host:    "localhost",
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		Redis: Redis{
+			Addr: "localhost:6379",
+		},
+		Lockout: Lockout{
+			MaxAccountFailures: 5,
+			MaxIPFailures:      50,
+			FailuresWindow:     time.Minute * 15,
+			BackoffBase:        time.Second,
+			BackoffMax:         time.Minute,
+			LockDuration:       time.Minute * 30,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "console",
+			Dir:    "./logs",
+
+			MaxSizeMB:      100,
+			RotateInterval: time.Hour * 24,
+			MaxAge:         time.Hour * 24 * 14,
+			MaxBackups:     14,
+			Compress:       true,
+			BufferSize:     1024,
+			Overflow:       "drop",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "billing",
+			SampleRatio: 1,
+		},
+		CORS: CORS{
+			MaxAge: time.Hour * 2,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			IP: RateLimitPolicy{
+				By:       "ip",
+				Requests: 600,
+				Window:   time.Minute,
+			},
+			Default: RateLimitPolicy{
+				By:       "principal",
+				Requests: 300,
+				Window:   time.Minute,
+			},
+		},
+		Notifier: Notifier{
+			WebhookTimeout: time.Second * 10,
+		},
+	}
+}
+
+// Read config from YAML file, override it by environment variables and
+// validate it. File is optional if path is empty
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = yaml.Unmarshal(file, &config)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+		}
+	}
+
+	err := applyEnv(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validate(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (s Server) validate() []error {
+	var errs []error
+	if s.DrainDelay >= s.ShutdownTimeout {
+		errs = append(errs, errors.New("server.drain_delay should be less than server.shutdown_timeout"))
+	}
+	for _, proxy := range s.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		if err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies should contain IP addresses or CIDRs, got %q", proxy))
+		}
+	}
+	return errs
+}
+
+func (l Lockout) validate() []error {
+	var errs []error
+	if l.BackoffMax < l.BackoffBase {
+		errs = append(errs, errors.New("lockout.backoff_max should not be less than lockout.backoff_base"))
+	}
+	return errs
+}
+
+func (l Log) validate() []error {
+	var errs []error
+	switch l.Level {
+	case "", "debug", "info", "warning", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level should be one of debug, info, warning, error, got %q", l.Level))
+	}
+	switch l.Format {
+	case "", "json", "console":
+	default:
+		errs = append(errs, fmt.Errorf("log.format should be json or console, got %q", l.Format))
+	}
+	switch l.Overflow {
+	case "", "drop", "block":
+	default:
+		errs = append(errs, fmt.Errorf("log.overflow should be drop or block, got %q", l.Overflow))
+	}
+	if l.MaxSizeMB < 0 || l.RotateInterval < 0 || l.MaxAge < 0 || l.MaxBackups < 0 {
+		errs = append(errs, errors.New("log.max_size_mb, log.rotate_interval, log.max_age and log.max_backups should not be negative"))
+	}
+	if l.BufferSize < 0 {
+		errs = append(errs, errors.New("log.buffer_size should not be negative"))
+	}
+	return errs
+}
+
+func (t Tracing) validate() []error {
+	var errs []error
+	switch t.Exporter {
+	case "", "none", "stdout":
+	case "otlp":
+		if t.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter should be one of none, stdout, otlp, got %q", t.Exporter))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio should be from 0 to 1"))
+	}
+	return errs
+}
+
+func (n Notifier) validate() []error {
+	var errs []error
+	switch n.Sender {
+	case "":
+	case "log":
+		if !n.Development {
+			errs = append(errs, errors.New("notifier.sender log does not deliver messages, it is accepted only when notifier.development is enabled"))
+		}
+	case "webhook":
+		parsed, err := url.Parse(n.WebhookURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			errs = append(errs, errors.New("notifier.webhook_url should be URL of gateway for webhook sender"))
+		}
+		if n.WebhookTimeout <= 0 {
+			errs = append(errs, errors.New("notifier.webhook_timeout should be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("notifier.sender should be one of log, webhook, got %q", n.Sender))
+	}
+	return errs
+}
+
+func (c CORS) validate() []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowed_origins cannot contain \"*\" when cors.allow_credentials is enabled"))
+			}
+			continue
+		}
+
+		// origin is scheme and host without path, e.g. https://app.example.com
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins should contain origins like https://app.example.com, got %q", origin))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age should not be negative"))
+	}
+	return errs
+}
+
+func (r RateLimit) validate() []error {
+	if !r.Enabled {
+		return nil
+	}
+
+	errs := r.Default.validate("rate_limit.default")
+	if r.IP.By != "ip" {
+		errs = append(errs, fmt.Errorf("rate_limit.ip.by should be ip, got %q", r.IP.By))
+	}
+	errs = append(errs, r.IP.validate("rate_limit.ip")...)
+	routes := make(map[string]bool)
+	for i, policy := range r.Routes {
+		path := fmt.Sprintf("rate_limit.routes[%d]", i)
+		if policy.Route == "" {
+			errs = append(errs, fmt.Errorf("%s.route is required", path))
+		}
+		if routes[policy.Route] {
+			errs = append(errs, fmt.Errorf("%s.route %q is listed more than once", path, policy.Route))
+		}
+		routes[policy.Route] = true
+		errs = append(errs, policy.validate(path)...)
+	}
+	return errs
+}
+
+func (p RateLimitPolicy) validate(path string) []error {
+	var errs []error
+	switch p.By {
+	case "ip", "principal":
+	default:
+		errs = append(errs, fmt.Errorf("%s.by should be ip or principal, got %q", path, p.By))
+	}
+	if p.Requests <= 0 || p.Window <= 0 {
+		errs = append(errs, fmt.Errorf("%s.requests and %s.window should be positive", path, path))
+	}
+	if p.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%s.burst should not be negative", path))
+	}
+	return errs
+}
+
+// Values of connection string are quoted as it is described in
+// documentation of lib/pq
+func quoteDSN(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Override fields of config by environment variables from "env" tags
+func applyEnv(config *Config) error {
+	var errs []string
+	walkFields(reflect.ValueOf(config).Elem(), "", func(field reflect.Value, tag reflect.StructTag, path string) {
+		name := tag.Get("env")
+		if name == "" {
+			return
+		}
+
+		value, ok, err := lookupEnv(name, tag.Get("secret") == "true")
+		if err != nil {
+			errs = append(errs, err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+
+		err = setField(field, value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: cannot parse %q for %s: %v", name, value, path, err))
+		}
+	})
+
+	if len(errs) > 0 {
+		return errors.New("config is not valid:\n  " + strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+// Check required fields and rules of sections
+func validate(config *Config) error {
+	var errs []string
+	walkFields(reflect.ValueOf(config).Elem(), "", func(field reflect.Value, tag reflect.StructTag, path string) {
+		if tag.Get("required") == "true" && field.IsZero() {
+			errs = append(errs, fmt.Sprintf("%s is required (set it in config file or %s environment variable)", path, tag.Get("env")))
+		}
+	})
+
+	for _, err := range config.Server.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.Lockout.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.Log.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.Tracing.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.CORS.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.RateLimit.validate() {
+		errs = append(errs, err.Error())
+	}
+	for _, err := range config.Notifier.validate() {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return errors.New("config is not valid:\n  " + strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+// Value of secret can be read from file which path is set in
+// environment variable with "_FILE" suffix
+func lookupEnv(name string, secret bool) (string, bool, error) {
+	if secret {
+		path, ok := os.LookupEnv(name + "_FILE")
+		if ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", false, fmt.Errorf("%s_FILE: cannot read secret: %v", name, err)
+			}
+			return strings.TrimRight(string(content), "\r\n"), true, nil
+		}
+	}
+
+	value, ok := os.LookupEnv(name)
+	return value, ok, nil
+}
+
+func walkFields(structure reflect.Value, prefix string, fn func(field reflect.Value, tag reflect.StructTag, path string)) {
+	for i := 0; i < structure.NumField(); i++ {
+		field := structure.Field(i)
+		fieldType := structure.Type().Field(i)
+
+		path := fieldType.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			walkFields(field, path, fn)
+			continue
+		}
+
+		fn(field, fieldType.Tag, path)
+	}
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(number))
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(flag)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		// list is separated by comma, e.g. https://a.example.com,https://b.example.com
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
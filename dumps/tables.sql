@@ -1,3 +1,8 @@
+CREATE TABLE roles (
+  name VARCHAR PRIMARY KEY,
+  description TEXT NOT NULL
+);
+
 CREATE TABLE users (
   id SERIAL PRIMARY KEY,
   firstname VARCHAR NOT NULL,
@@ -6,7 +11,9 @@ CREATE TABLE users (
   email VARCHAR UNIQUE,
   phone VARCHAR UNIQUE NOT NULL,
   password VARCHAR NOT NULL,
-  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+  role VARCHAR NOT NULL DEFAULT 'user',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  FOREIGN KEY (role) REFERENCES roles(name)
 );
 
 CREATE TABLE passports (
@@ -58,6 +65,78 @@ CREATE TABLE transactions (
   FOREIGN KEY (state_id) REFERENCES transaction_states(id) ON DELETE CASCADE
 );
 
+CREATE TABLE user_totp (
+  user_id BIGINT PRIMARY KEY,
+  secret VARCHAR NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  enabled_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_codes (
+  id SERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  code_hash VARCHAR NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE account_lockouts (
+  id SERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  ip VARCHAR NOT NULL,
+  locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP NOT NULL,
+  unlocked_at TIMESTAMP DEFAULT NULL,
+  unlock_reason VARCHAR DEFAULT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_clients (
+  client_id VARCHAR PRIMARY KEY,
+  name VARCHAR NOT NULL,
+  secret_hash VARCHAR NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE TABLE api_keys (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name VARCHAR NOT NULL,
+  prefix VARCHAR UNIQUE NOT NULL,
+  key_hash VARCHAR NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  rotated_at TIMESTAMP DEFAULT NULL,
+  last_used_at TIMESTAMP DEFAULT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id BIGINT NOT NULL,
+  action VARCHAR NOT NULL,
+  target_type VARCHAR NOT NULL,
+  target_id VARCHAR NOT NULL,
+  reason TEXT DEFAULT NULL,
+  details JSONB DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_log_target_idx ON audit_log (target_type, target_id);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id);
+
+INSERT INTO roles (name, description) VALUES ('admin', 'Administrator');
+INSERT INTO roles (name, description) VALUES ('merchant', 'Merchant');
+INSERT INTO roles (name, description) VALUES ('user', 'User');
+INSERT INTO roles (name, description) VALUES ('visitor', 'Visitor');
 
 INSERT INTO card_states (id, description) VALUES (0, 'Not Activated');
 INSERT INTO card_states (id, description) VALUES (1, 'Activated');
@@ -69,7 +148,8 @@ INSERT INTO transaction_states (id, description) VALUES (1, 'Success');
 INSERT INTO transaction_states (id, description) VALUES (2, 'Not enough balance');
 INSERT INTO transaction_states (id, description) VALUES (3, 'Refund');
 
-INSERT INTO users (firstname, lastname, patronymic, email, phone, password) VALUES ('Joe', 'Brown', 'Jackman', 'joe@mail.com', '79876543210', '123456');
+INSERT INTO users (firstname, lastname, patronymic, email, phone, password) VALUES ('Joe', 'Brown', 'Jackman', 'joe@mail.com', '79876543210', '$2a$10$LcmPP/YIgG//dmQeeQwi0.POKhrilUiLXttGoPz8lqHPOmdSgVnQi');
+INSERT INTO users (firstname, lastname, patronymic, email, phone, password, role) VALUES ('Ann', 'Smith', 'Olegovna', 'admin@mail.com', '79876543211', '$2a$10$LcmPP/YIgG//dmQeeQwi0.POKhrilUiLXttGoPz8lqHPOmdSgVnQi', 'admin');
 INSERT INTO passports (user_id, serial, number, issued_by, issued_date) VALUES(1, 1234, 567890, 'Mars, Mountain #2', CURRENT_TIMESTAMP);
 INSERT INTO cards (user_id, number, mask, cvc, state_id) VALUES (1, '8765564332450001', '8765 **** **** 0001', 789, 0);
 INSERT INTO cards (user_id, number, mask, cvc, state_id) VALUES (1, '8765564332450002', '8765 **** **** 0002', 256, 0);
\ No newline at end of file
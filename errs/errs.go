@@ -0,0 +1,116 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+// Codes of common errors. Services declare their own codes for errors
+// which clients can handle, e.g. token_not_valid or card_is_outdated
+const (
+	CodeBodyNotValid = "body_not_valid"
+	CodeBodyTooLarge = "body_too_large"
+	CodeNotFound     = "not_found"
+	CodeNotAllowed   = "method_not_allowed"
+	CodeValidation   = "validation_failed"
+	CodeInternal     = "internal"
+)
+
+// Error which can be shown to client. Code is stable and should be used
+// by clients to handle error, Message is for humans and can change.
+// Errors of services should be declared once and compared with errors.Is
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	// Additional information for client, e.g. list of invalid fields
+	Details any
+
+	origin *Error
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Copy of error with details. Copy is equal to e for errors.Is
+func (e *Error) WithDetails(details any) *Error {
+	result := *e
+	result.Details = details
+	result.origin = e
+	if e.origin != nil {
+		result.origin = e.origin
+	}
+	return &result
+}
+
+func (e *Error) Is(target error) bool {
+	return e.origin != nil && e.origin == target
+}
+
+func BadRequest(code string, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(http.StatusForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+func Unprocessable(code string, message string) *Error {
+	return New(http.StatusUnprocessableEntity, code, message)
+}
+
+func TooManyRequests(code string, message string) *Error {
+	return New(http.StatusTooManyRequests, code, message)
+}
+
+var (
+	ErrBodyNotValid = BadRequest(CodeBodyNotValid, "request body is not valid")
+	ErrBodyTooLarge = New(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body is too large")
+	ErrNotFound     = NotFound(CodeNotFound, "resource was not found")
+	ErrNotAllowed   = New(http.StatusMethodNotAllowed, CodeNotAllowed, "method is not allowed")
+	ErrValidation   = Unprocessable(CodeValidation, "request is not valid")
+	ErrInternal     = New(http.StatusInternalServerError, CodeInternal, "internal server error")
+)
+
+// Error which should be sent to client. Missing rows become ErrNotFound,
+// any other error which is not *Error is internal and its details are
+// replaced with ErrInternal, so messages of Postgres or Redis do not leak
+func Resolve(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	return ErrInternal
+}
+
+// Error is not caused by client and should be logged
+func IsInternal(err error) bool {
+	return Resolve(err).Status >= http.StatusInternalServerError
+}
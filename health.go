@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/services/health"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) Healthz(ctx *rou.Context) {
+	writeHealthJSON(ctx, http.StatusOK, r.Health.Live(), nil)
+}
+
+func (r *Repository) Readyz(ctx *rou.Context) {
+	report := r.Health.Ready(ctx.Request().Context())
+	for name, check := range report.Checks {
+		if check.Error != nil {
+			r.log(ctx).Warning("dependency is down", logger.String("dependency", name), logger.Err(check.Error))
+		}
+	}
+	if report.Status == health.StatusUp {
+		writeHealthJSON(ctx, http.StatusOK, report, nil)
+		return
+	}
+
+	err := health.ErrNotReady
+	if report.ShuttingDown {
+		err = health.ErrShuttingDown
+	}
+	writeHealthJSON(ctx, err.Status, report, err)
+}
+
+// Report is sent in body of response even if service is not ready,
+// so orchestrator can see which dependency is down
+func writeHealthJSON(ctx *rou.Context, status int, report health.Report, err *errs.Error) {
+	response := utils.ResponseObject[health.Report]{Body: report}
+	if err != nil {
+		response.Error = utils.NewErrorObject(ctx.Request(), err)
+	}
+
+	ctx.ResponseWriter().Header().Set("Cache-Control", "no-store")
+	utils.WriteJSON(ctx.ResponseWriter(), status, response)
+}
@@ -0,0 +1,88 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Component of application which should be started before server
+// accepts requests and stopped after it. Start and Stop are optional
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Register hook. Hooks are started in order of registration and
+// stopped in reverse order
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start all hooks in order. If one of them fails, hooks which were
+// already started are stopped and error is returned
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.started < len(l.hooks) {
+		hook := l.hooks[l.started]
+		if hook.Start != nil {
+			err := hook.Start(ctx)
+			if err != nil {
+				startErr := fmt.Errorf("cannot start %s: %w", hook.Name, err)
+				stopErr := l.stop(ctx)
+				if stopErr != nil {
+					return fmt.Errorf("%w\n  %v", startErr, stopErr)
+				}
+				return startErr
+			}
+		}
+		l.started++
+	}
+
+	return nil
+}
+
+// Stop started hooks in reverse order. Every hook is stopped even if
+// previous one has failed, all errors are returned together
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stop(ctx)
+}
+
+func (l *Lifecycle) stop(ctx context.Context) error {
+	var errs []string
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.Stop == nil {
+			continue
+		}
+		err := hook.Stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("cannot stop %s: %v", hook.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Moranilt/billing/services/lockout"
+	"github.com/Moranilt/billing/services/otp"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) LoginUnlockRequest(ctx *rou.Context) {
+	var body lockout.UnlockRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	account, err := r.User.GetAccount(ctx.Request().Context(), body.Login)
+	// response is the same for unknown login, so it cannot be used
+	// to find out registered users
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.SuccessJSONResponse(nil)
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	locked, err := r.Lockout.IsLocked(ctx.Request().Context(), account.Id)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+	if !locked {
+		ctx.SuccessJSONResponse(nil)
+		return
+	}
+
+	err = r.OTP.Send(ctx.Request().Context(), otp.PurposeUnlock, account.Phone)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
+
+func (r *Repository) LoginUnlockVerify(ctx *rou.Context) {
+	var body lockout.UnlockVerifyRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	account, err := r.User.GetAccount(ctx.Request().Context(), body.Login)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.fail(ctx, otp.ErrCodeExpired)
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.OTP.Verify(ctx.Request().Context(), otp.PurposeUnlock, account.Phone, body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Lockout.Unlock(ctx.Request().Context(), account.Id, lockout.UnlockReasonOTP)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+type contextFields struct {
+	mu     sync.Mutex
+	fields []Field
+}
+
+// Returns context which collects fields for records written with it,
+// e.g. request id, route and user id of request. Fields are added by
+// AddFields while request goes through middlewares
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, &contextFields{})
+}
+
+// Add fields to context created by NewContext. Does nothing for
+// other contexts
+func AddFields(ctx context.Context, fields ...Field) {
+	stored, ok := ctx.Value(contextKey{}).(*contextFields)
+	if !ok {
+		return
+	}
+
+	stored.mu.Lock()
+	stored.fields = append(stored.fields, fields...)
+	stored.mu.Unlock()
+}
+
+// Returns copy of fields added to context
+func FieldsFromContext(ctx context.Context) []Field {
+	stored, ok := ctx.Value(contextKey{}).(*contextFields)
+	if !ok {
+		return nil
+	}
+
+	stored.mu.Lock()
+	defer stored.mu.Unlock()
+	return append([]Field(nil), stored.fields...)
+}
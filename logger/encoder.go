@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+type record struct {
+	time    time.Time
+	level   Level
+	caller  string
+	message string
+	fields  []Field
+}
+
+type encoder interface {
+	encode(rec record, colored bool) []byte
+}
+
+func newEncoder(format string) (encoder, error) {
+	switch format {
+	case FormatJSON:
+		return jsonEncoder{}, nil
+	case FormatConsole:
+		return consoleEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// One JSON object per line. Fields follow time, level, caller and msg
+// in order they were added
+type jsonEncoder struct{}
+
+func (jsonEncoder) encode(rec record, colored bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, rec.time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, rec.level.String())
+	buf.WriteString(`,"caller":`)
+	writeJSON(&buf, rec.caller)
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, rec.message)
+	for _, field := range rec.fields {
+		buf.WriteByte(',')
+		writeJSON(&buf, field.Key)
+		buf.WriteByte(':')
+		writeJSON(&buf, field.Value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, value any) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(content)
+}
+
+var (
+	yellowBg = string([]byte{27, 91, 57, 48, 59, 52, 51, 109})
+	redBg    = string([]byte{27, 91, 57, 55, 59, 52, 49, 109})
+	blueBg   = string([]byte{27, 91, 56, 55, 59, 52, 52, 109})
+	reset    = string([]byte{27, 91, 48, 109})
+)
+
+// Human readable line with fields as key=value
+type consoleEncoder struct{}
+
+func (consoleEncoder) encode(rec record, colored bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(formatTime(rec.time))
+	buf.WriteByte(' ')
+	buf.WriteString(levelLabel(rec.level, colored))
+	buf.WriteByte(' ')
+	buf.WriteString(rec.caller)
+	buf.WriteString(": ")
+	buf.WriteString(rec.message)
+	for _, field := range rec.fields {
+		buf.WriteByte(' ')
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		buf.WriteString(consoleValue(field.Value))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func levelLabel(level Level, colored bool) string {
+	var label, color string
+	switch level {
+	case DebugLevel:
+		label = "DEBUG:"
+	case InfoLevel:
+		label, color = "INFO:", blueBg
+	case WarningLevel:
+		label, color = "WARNING:", yellowBg
+	case ErrorLevel:
+		label, color = "ERROR:", redBg
+	}
+
+	if !colored || color == "" {
+		return label
+	}
+	return color + " " + label + " " + reset
+}
+
+// Strings with spaces or quotes are quoted, other values are written
+// as JSON
+func consoleValue(value any) string {
+	if text, ok := value.(string); ok {
+		if text == "" || bytes.ContainsAny([]byte(text), " \t\r\n\"=") {
+			return strconv.Quote(text)
+		}
+		return text
+	}
+
+	var buf bytes.Buffer
+	writeJSON(&buf, value)
+	return buf.String()
+}
+
+func itoa(i int, wid int) string {
+	var b [20]byte
+	bp := len(b) - 1
+	for i >= 10 || wid > 1 {
+		wid--
+		q := i / 10
+		b[bp] = byte('0' + i - q*10)
+		bp--
+		i = q
+	}
+
+	b[bp] = byte('0' + i)
+	return string(b[bp:])
+}
+
+func formatTime(t time.Time) string {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	return fmt.Sprintf("%d/%s/%s %s:%s:%s", year, itoa(int(month), 2), itoa(day, 2), itoa(hour, 2), itoa(min, 2), itoa(sec, 2))
+}
+
+func formatCaller(file string, line int) string {
+	fileName := file
+	for i := len(file) - 1; i >= 0; i-- {
+		if file[i] == '/' {
+			fileName = file[i+1:]
+			break
+		}
+	}
+	return fileName + ":" + itoa(line, -1)
+}
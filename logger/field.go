@@ -0,0 +1,42 @@
+package logger
+
+import "time"
+
+// Key/value pair which is added to record
+type Field struct {
+	Key   string
+	Value any
+}
+
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value.String()}
+}
+
+// Field with text of error under "error" key
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// Value should be serializable to JSON
+func Any(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import "fmt"
+
+type Level int8
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarningLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarningLevel:
+		return "warning"
+	case ErrorLevel:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", l)
+}
+
+// Parse name of level from config
+func ParseLevel(name string) (Level, error) {
+	for level := DebugLevel; level <= ErrorLevel; level++ {
+		if level.String() == name {
+			return level, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", name)
+}
@@ -1,108 +1,261 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Moranilt/billing/config"
+)
+
+const (
+	// Record is dropped when buffer is full
+	OverflowDrop = "drop"
+	// Caller waits until there is space in buffer
+	OverflowBlock = "block"
 )
 
+// Destinations of records which are shared by logger and loggers
+// created from it by With and WithContext. Records are written by
+// one background worker in order they were added to buffer
+type output struct {
+	encoder  encoder
+	colored  bool
+	overflow string
+	stdout   io.Writer
+	stderr   io.Writer
+	info     *rotatingFile
+	warn     *rotatingFile
+	err      *rotatingFile
+
+	// Closed is guarded by mu, records are not accepted after Close
+	mu      sync.RWMutex
+	closed  bool
+	records chan record
+	dropped int64
+	done    chan struct{}
+}
+
 type customLogger struct {
-	info io.Writer
-	warn io.Writer
-	err  io.Writer
+	level  Level
+	out    *output
+	fields []Field
 }
 
 type LoggerWriter interface {
-	Info(string)
-	Warning(string)
-	Error(string)
-	Message(message string) string
+	Debug(message string, fields ...Field)
+	Info(message string, fields ...Field)
+	Warning(message string, fields ...Field)
+	Error(message string, fields ...Field)
+	// Logger which adds fields to every record
+	With(fields ...Field) LoggerWriter
+	// Logger which adds fields collected in context by AddFields,
+	// e.g. request id, route and user id
+	WithContext(ctx context.Context) LoggerWriter
+	// Write buffered records, flush them to disk and close files
+	Close() error
 }
 
-func NewLogger() LoggerWriter {
-	infoLogs, err := os.OpenFile("./logs/info.log", os.O_WRONLY|os.O_APPEND, 0600)
+// Create logger which writes to files in directory from settings.
+// Directory and files are created if they do not exist
+func NewLogger(settings config.Log) (LoggerWriter, error) {
+	level, err := ParseLevel(settings.Level)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	errorLogs, err := os.OpenFile("./logs/error.log", os.O_WRONLY|os.O_APPEND, 0600)
+	encoder, err := newEncoder(settings.Format)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	warningLogs, err := os.OpenFile("./logs/warning.log", os.O_WRONLY|os.O_APPEND, 0600)
-	if err != nil {
-		log.Fatal(err)
+	switch settings.Overflow {
+	case OverflowDrop, OverflowBlock:
+	default:
+		return nil, fmt.Errorf("unknown log overflow policy %q", settings.Overflow)
 	}
 
-	return &customLogger{
-		info: infoLogs,
-		warn: warningLogs,
-		err:  errorLogs,
+	rotate := RotateSettings{
+		MaxSize:    int64(settings.MaxSizeMB) * 1024 * 1024,
+		Interval:   settings.RotateInterval,
+		MaxAge:     settings.MaxAge,
+		MaxBackups: settings.MaxBackups,
+		Compress:   settings.Compress,
 	}
-}
 
-var (
-	yellowBg = string([]byte{27, 91, 57, 48, 59, 52, 51, 109})
-	redBg    = string([]byte{27, 91, 57, 55, 59, 52, 49, 109})
-	blueBg   = string([]byte{27, 91, 56, 55, 59, 52, 52, 109})
-	reset    = string([]byte{27, 91, 48, 109})
-)
+	var files []*rotatingFile
+	for _, name := range []string{"info.log", "warning.log", "error.log"} {
+		file, err := openRotatingFile(filepath.Join(settings.Dir, name), rotate)
+		if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
 
-func itoa(i int, wid int) string {
-	var b [20]byte
-	bp := len(b) - 1
-	for i >= 10 || wid > 1 {
-		wid--
-		q := i / 10
-		b[bp] = byte('0' + i - q*10)
-		bp--
-		i = q
+	out := &output{
+		encoder:  encoder,
+		colored:  settings.Format == FormatConsole,
+		overflow: settings.Overflow,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+		info:     files[0],
+		warn:     files[1],
+		err:      files[2],
+		records:  make(chan record, settings.BufferSize),
+		done:     make(chan struct{}),
 	}
+	go out.run()
 
-	b[bp] = byte('0' + i)
-	return string(b[bp:])
+	return &customLogger{
+		level: level,
+		out:   out,
+	}, nil
 }
 
-func formatFileRow(t time.Time, file string, line int) string {
-	var fileName string
-	for i := len(file) - 1; i >= 0; i-- {
-		if file[i] == '/' {
-			fileName = file[i+1:]
-			break
-		}
+func (l *customLogger) Debug(message string, fields ...Field) {
+	l.write(DebugLevel, message, fields)
+}
+
+func (l *customLogger) Info(message string, fields ...Field) {
+	l.write(InfoLevel, message, fields)
+}
+
+func (l *customLogger) Warning(message string, fields ...Field) {
+	l.write(WarningLevel, message, fields)
+}
+
+func (l *customLogger) Error(message string, fields ...Field) {
+	l.write(ErrorLevel, message, fields)
+}
+
+func (l *customLogger) With(fields ...Field) LoggerWriter {
+	if len(fields) == 0 {
+		return l
+	}
+
+	combined := make([]Field, 0, len(l.fields)+len(fields))
+	combined = append(combined, l.fields...)
+	combined = append(combined, fields...)
+	return &customLogger{
+		level:  l.level,
+		out:    l.out,
+		fields: combined,
 	}
+}
 
-	year, month, day := t.Date()
-	hour, min, sec := t.Clock()
-	timestamp := fmt.Sprintf("%d/%s/%s %s:%s:%s", year, itoa(int(month), 2), itoa(day, 2), itoa(hour, 2), itoa(min, 2), itoa(sec, 2))
-	return fmt.Sprintf("%s %s:%s:", timestamp, fileName, itoa(line, -1))
+func (l *customLogger) WithContext(ctx context.Context) LoggerWriter {
+	return l.With(FieldsFromContext(ctx)...)
 }
 
-func (l *customLogger) Message(message string) string {
-	now := time.Now()
+// Should be called only by Debug, Info, Warning and Error, otherwise
+// caller of record is wrong
+func (l *customLogger) write(level Level, message string, fields []Field) {
+	if level < l.level {
+		return
+	}
 
-	_, file, line, ok := runtime.Caller(1)
+	_, file, line, ok := runtime.Caller(2)
 	if !ok {
 		file = "???"
 		line = 0
 	}
 
-	return fmt.Sprintf("%s %s\n", formatFileRow(now, file, line), message)
+	rec := record{
+		time:    time.Now(),
+		level:   level,
+		caller:  formatCaller(file, line),
+		message: message,
+		fields:  append(append([]Field(nil), l.fields...), fields...),
+	}
+
+	l.out.enqueue(rec)
 }
 
-func (l *customLogger) Info(message string) {
-	os.Stdout.WriteString(blueBg + " INFO: " + reset + " " + message)
-	l.info.Write([]byte("INFO: " + message))
+// Add record to buffer. When buffer is full record is dropped or caller
+// waits, depending on overflow policy
+func (o *output) enqueue(rec record) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return
+	}
+
+	if o.overflow == OverflowBlock {
+		o.records <- rec
+		return
+	}
+
+	select {
+	case o.records <- rec:
+	default:
+		atomic.AddInt64(&o.dropped, 1)
+	}
 }
 
-func (l *customLogger) Warning(message string) {
-	os.Stdout.WriteString(yellowBg + " WARNING: " + reset + " " + message)
-	l.warn.Write([]byte("WARNING: " + message))
+func (o *output) run() {
+	defer close(o.done)
+	for rec := range o.records {
+		if dropped := atomic.SwapInt64(&o.dropped, 0); dropped > 0 {
+			o.write(record{
+				time:    time.Now(),
+				level:   WarningLevel,
+				caller:  "logger",
+				message: "log records were dropped because buffer is full",
+				fields:  []Field{Int64("dropped", dropped)},
+			})
+		}
+		o.write(rec)
+	}
+}
+
+func (o *output) write(rec record) {
+	console := o.encoder.encode(rec, o.colored)
+	plain := console
+	if o.colored {
+		plain = o.encoder.encode(rec, false)
+	}
+
+	switch rec.level {
+	case ErrorLevel:
+		o.stderr.Write(console)
+		o.err.Write(plain)
+	case WarningLevel:
+		o.stdout.Write(console)
+		o.warn.Write(plain)
+	default:
+		o.stdout.Write(console)
+		o.info.Write(plain)
+	}
 }
 
-func (l *customLogger) Error(message string) {
-	os.Stderr.WriteString(redBg + " ERROR: " + reset + " " + message)
-	l.err.Write([]byte("ERROR: " + message))
+// Records which are already in buffer are written before files are
+// closed. Records added after Close are ignored
+func (l *customLogger) Close() error {
+	o := l.out
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil
+	}
+	o.closed = true
+	close(o.records)
+	o.mu.Unlock()
+
+	<-o.done
+
+	var firstErr error
+	for _, file := range []*rotatingFile{o.info, o.warn, o.err} {
+		err := file.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Output with files in temporary directory. Worker is not started, so
+// records stay in buffer until test starts it
+func testOutput(t *testing.T, overflow string, bufferSize int) *output {
+	t.Helper()
+	dir := t.TempDir()
+	var files []*rotatingFile
+	for _, name := range []string{"info.log", "warning.log", "error.log"} {
+		file, err := openRotatingFile(filepath.Join(dir, name), RotateSettings{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	return &output{
+		encoder:  jsonEncoder{},
+		overflow: overflow,
+		stdout:   &bytes.Buffer{},
+		stderr:   &bytes.Buffer{},
+		info:     files[0],
+		warn:     files[1],
+		err:      files[2],
+		records:  make(chan record, bufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+func testRecord(message string) record {
+	return record{time: time.Now(), level: InfoLevel, caller: "test", message: message}
+}
+
+func TestDropWhenBufferIsFull(t *testing.T) {
+	out := testOutput(t, OverflowDrop, 1)
+	logger := &customLogger{level: DebugLevel, out: out}
+
+	enqueued := make(chan struct{})
+	go func() {
+		for _, message := range []string{"kept", "dropped", "dropped too"} {
+			out.enqueue(testRecord(message))
+		}
+		close(enqueued)
+	}()
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatal("expected records to be dropped without waiting")
+	}
+
+	go out.run()
+	err := logger.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := readFile(t, out.info.path)
+	if !strings.Contains(info, `"msg":"kept"`) || strings.Contains(info, "dropped") {
+		t.Fatalf("expected only record which fits buffer to be written, got %q", info)
+	}
+	warning := readFile(t, out.warn.path)
+	if !strings.Contains(warning, "log records were dropped") || !strings.Contains(warning, `"dropped":2`) {
+		t.Fatalf("expected report of 2 dropped records, got %q", warning)
+	}
+}
+
+func TestBlockWaitsForBuffer(t *testing.T) {
+	out := testOutput(t, OverflowBlock, 1)
+	logger := &customLogger{level: DebugLevel, out: out}
+
+	out.enqueue(testRecord("first"))
+	enqueued := make(chan struct{})
+	go func() {
+		out.enqueue(testRecord("second"))
+		close(enqueued)
+	}()
+	select {
+	case <-enqueued:
+		t.Fatal("expected caller to wait while buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	go out.run()
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatal("expected caller to continue when buffer has space")
+	}
+	err := logger.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := readFile(t, out.info.path)
+	if !strings.Contains(info, `"msg":"first"`) || !strings.Contains(info, `"msg":"second"`) {
+		t.Fatalf("expected every record to be written, got %q", info)
+	}
+}
+
+func TestRecordsAfterCloseAreIgnored(t *testing.T) {
+	out := testOutput(t, OverflowBlock, 0)
+	logger := &customLogger{level: DebugLevel, out: out}
+	go out.run()
+
+	err := logger.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// does not panic on closed buffer and does not wait
+	logger.Info("after close")
+
+	if info := readFile(t, out.info.path); info != "" {
+		t.Fatalf("expected record after close to be ignored, got %q", info)
+	}
+}
@@ -0,0 +1,276 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of UTC time in names of rotated files, e.g. info-2022-05-01T10-00-00.000.log
+const backupTimeLayout = "2006-01-02T15-04-05.000"
+
+type RotateSettings struct {
+	// File is rotated when it becomes bigger. Zero disables rotation by size
+	MaxSize int64
+	// File is rotated when interval since its creation is over, e.g. every
+	// day. Zero disables rotation by time
+	Interval time.Duration
+	// Rotated files older than this are removed. Zero keeps all of them
+	MaxAge time.Duration
+	// Number of rotated files which are kept. Zero keeps all of them
+	MaxBackups int
+	// Rotated files are compressed with gzip
+	Compress bool
+}
+
+// File which is created if it does not exist and is rotated by size and
+// time. Compression and removal of old files are made in background
+type rotatingFile struct {
+	path     string
+	settings RotateSettings
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+func openRotatingFile(path string, settings RotateSettings) (*rotatingFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &rotatingFile{
+		path:     path,
+		settings: settings,
+		millCh:   make(chan struct{}, 1),
+	}
+
+	err = f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	f.millWg.Add(1)
+	go f.mill()
+	f.startMill()
+
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.settings.Interval > 0 {
+		f.rotateAt = time.Now().Truncate(f.settings.Interval).Add(f.settings.Interval)
+	}
+	return nil
+}
+
+// Should not be called concurrently
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.shouldRotate(int64(len(p))) {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) shouldRotate(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.settings.MaxSize > 0 && f.size+next > f.settings.MaxSize {
+		return true
+	}
+	return !f.rotateAt.IsZero() && !time.Now().Before(f.rotateAt)
+}
+
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.path, f.backupName(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	err = f.open()
+	if err != nil {
+		return err
+	}
+
+	f.startMill()
+	return nil
+}
+
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext)
+	return prefix + "-" + t.UTC().Format(backupTimeLayout) + ext
+}
+
+// Flush file to disk, wait for background work and close file
+func (f *rotatingFile) Close() error {
+	close(f.millCh)
+	f.millWg.Wait()
+
+	err := f.file.Sync()
+	closeErr := f.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (f *rotatingFile) startMill() {
+	select {
+	case f.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (f *rotatingFile) mill() {
+	defer f.millWg.Done()
+	for range f.millCh {
+		f.millOnce()
+	}
+}
+
+type backupFile struct {
+	path      string
+	createdAt time.Time
+}
+
+// Compress rotated files and remove old ones. Errors are ignored, files
+// are processed again after next rotation
+func (f *rotatingFile) millOnce() {
+	backups := f.backups()
+
+	var remove []backupFile
+	if f.settings.MaxBackups > 0 && len(backups) > f.settings.MaxBackups {
+		remove = append(remove, backups[f.settings.MaxBackups:]...)
+		backups = backups[:f.settings.MaxBackups]
+	}
+	if f.settings.MaxAge > 0 {
+		cutoff := time.Now().Add(-f.settings.MaxAge)
+		kept := backups[:0]
+		for _, backup := range backups {
+			if backup.createdAt.Before(cutoff) {
+				remove = append(remove, backup)
+			} else {
+				kept = append(kept, backup)
+			}
+		}
+		backups = kept
+	}
+
+	for _, backup := range remove {
+		os.Remove(backup.path)
+	}
+
+	if !f.settings.Compress {
+		return
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup.path, ".gz") {
+			compressFile(backup.path)
+		}
+	}
+}
+
+// Rotated files sorted from newest to oldest
+func (f *rotatingFile) backups() []backupFile {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		createdAt, err := time.Parse(backupTimeLayout, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backupFile{
+			path:      filepath.Join(dir, name),
+			createdAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].createdAt.After(backups[j].createdAt)
+	})
+	return backups
+}
+
+// Original file is removed only when compressed file is written completely
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "info.log")
+	f, err := openRotatingFile(path, RotateSettings{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "too long for one file\n"} {
+		_, err := f.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// names of rotated files differ by milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// record which is larger than limit is written to new file as is
+	if content := readFile(t, path); content != "too long for one file\n" {
+		t.Fatalf("expected current file to contain last record, got %q", content)
+	}
+
+	backups := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 rotated files, got %d", len(backups))
+	}
+	if content := readFile(t, backups[0].path); content != "second\n" {
+		t.Fatalf("expected newest rotated file to contain second record, got %q", content)
+	}
+	if content := readFile(t, backups[1].path); content != "first\n" {
+		t.Fatalf("expected oldest rotated file to contain first record, got %q", content)
+	}
+}
+
+func TestRotateAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "info.log")
+	err := os.WriteFile(path, []byte("old\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openRotatingFile(path, RotateSettings{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// size of existing file counts toward limit
+	_, err = f.Write([]byte("new record\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if content := readFile(t, path); content != "new record\n" {
+		t.Fatalf("expected existing file to be rotated, got %q", content)
+	}
+	if backups := f.backups(); len(backups) != 1 || readFile(t, backups[0].path) != "old\n" {
+		t.Fatalf("expected existing records in rotated file, got %v", backups)
+	}
+}
+
+func TestRemoveBackupsPastRetention(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		settings RotateSettings
+		kept     []time.Duration
+	}{
+		{
+			name:     "keep all",
+			settings: RotateSettings{},
+			kept:     []time.Duration{time.Hour, 2 * time.Hour, 48 * time.Hour},
+		},
+		{
+			name:     "max age",
+			settings: RotateSettings{MaxAge: 24 * time.Hour},
+			kept:     []time.Duration{time.Hour, 2 * time.Hour},
+		},
+		{
+			name:     "max backups",
+			settings: RotateSettings{MaxBackups: 1},
+			kept:     []time.Duration{time.Hour},
+		},
+		{
+			name:     "max age and max backups",
+			settings: RotateSettings{MaxAge: 24 * time.Hour, MaxBackups: 2},
+			kept:     []time.Duration{time.Hour, 2 * time.Hour},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f := &rotatingFile{path: filepath.Join(dir, "info.log"), settings: test.settings}
+			for _, age := range []time.Duration{2 * time.Hour, 48 * time.Hour, time.Hour} {
+				writeFile(t, f.backupName(now.Add(-age)), "record\n")
+			}
+			// files of other logs and current file are not touched
+			writeFile(t, filepath.Join(dir, "info.log"), "current\n")
+			writeFile(t, filepath.Join(dir, "error-"+now.Add(-48*time.Hour).UTC().Format(backupTimeLayout)+".log"), "error\n")
+
+			f.millOnce()
+
+			// rotated files are listed from newest to oldest
+			var expected []string
+			for _, age := range test.kept {
+				expected = append(expected, f.backupName(now.Add(-age)))
+			}
+			var actual []string
+			for _, backup := range f.backups() {
+				actual = append(actual, backup.path)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("expected rotated files %v, got %v", expected, actual)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != len(expected)+2 {
+				t.Fatalf("expected other files to be kept, got %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestCompressBackups(t *testing.T) {
+	dir := t.TempDir()
+	f := &rotatingFile{path: filepath.Join(dir, "info.log"), settings: RotateSettings{Compress: true, MaxBackups: 1}}
+	newest := f.backupName(time.Now().Add(-time.Minute))
+	oldest := f.backupName(time.Now().Add(-time.Hour))
+	writeFile(t, newest, "newest\n")
+	writeFile(t, oldest, "oldest\n")
+
+	f.millOnce()
+
+	if _, err := os.Stat(newest); !os.IsNotExist(err) {
+		t.Fatalf("expected rotated file to be replaced by compressed one, got %v", err)
+	}
+	if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+		t.Fatalf("expected file over max backups to be removed, got %v", err)
+	}
+
+	file, err := os.Open(newest + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "newest\n" {
+		t.Fatalf("expected compressed records, got %q", content)
+	}
+
+	// compressed files are counted as rotated files
+	backups := f.backups()
+	if len(backups) != 1 || backups[0].path != newest+".gz" {
+		t.Fatalf("expected compressed file in rotated files, got %v", backups)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,163 +2,513 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/Moranilt/billing/config"
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/lifecycle"
 	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/metrics"
+	"github.com/Moranilt/billing/openapi"
 	"github.com/Moranilt/billing/services"
+	"github.com/Moranilt/billing/services/admin"
+	"github.com/Moranilt/billing/services/apikeys"
+	"github.com/Moranilt/billing/services/audit"
 	"github.com/Moranilt/billing/services/auth"
 	"github.com/Moranilt/billing/services/cards"
+	"github.com/Moranilt/billing/services/health"
+	"github.com/Moranilt/billing/services/lockout"
+	"github.com/Moranilt/billing/services/notifier"
+	"github.com/Moranilt/billing/services/oauth"
+	"github.com/Moranilt/billing/services/otp"
+	"github.com/Moranilt/billing/services/password"
+	"github.com/Moranilt/billing/services/ratelimit"
+	"github.com/Moranilt/billing/services/twofactor"
 	"github.com/Moranilt/billing/services/user"
+	"github.com/Moranilt/billing/tracing"
 	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/billing/validate"
 	"github.com/go-redis/redis/v8"
 
 	"github.com/Moranilt/rou"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-type ChannelsList struct {
-	info chan string
-	err  chan error
-	warn chan string
-}
-
 type Repository struct {
 	Authorization auth.Authentication
 	User          user.UserMethods
 	Cards         cards.CardsMethods
+	Admin         admin.AdminMethods
+	TwoFactor     twofactor.TwoFactorMethods
+	OTP           otp.OTPMethods
+	Lockout       lockout.LockoutMethods
+	Password      password.PasswordMethods
+	Notifier      notifier.Notifier
+	OAuth         oauth.ClientsMethods
+	Audit         audit.AuditMethods
+	APIKeys       apikeys.APIKeysMethods
+	Health        health.HealthMethods
 	logger        logger.LoggerWriter
-	channels      ChannelsList
+	spec          *openapi.Document
+}
+
+// Logger with fields of request: request id, route and user id
+func (r *Repository) log(ctx *rou.Context) logger.LoggerWriter {
+	return r.logger.WithContext(ctx.Request().Context())
+}
+
+// Write error to client. Internal errors are logged, client gets only
+// common message instead of them
+func (r *Repository) fail(ctx *rou.Context, err error) {
+	if errs.IsInternal(err) {
+		r.log(ctx).Error("request failed", logger.Err(err))
+	}
+	utils.ErrorResponse(ctx, err)
 }
 
 func (r *Repository) Login(ctx *rou.Context) {
-	err := r.Authorization.CreateTokens(ctx.ResponseWriter(), 1, "user")
+	var body user.Credentials
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	attempt := lockout.Attempt{
+		Login: body.Login,
+		IP:    utils.ClientIP(ctx.Request()),
+	}
+	account, err := r.User.GetAccount(ctx.Request().Context(), body.Login)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.fail(ctx, err)
+		return
+	}
+	if account != nil {
+		attempt.UserId = account.Id
+	}
+
+	wait, err := r.Lockout.Check(ctx.Request().Context(), attempt)
+	if errors.Is(err, lockout.ErrAccountLocked) || errors.Is(err, lockout.ErrTooManyAttempts) {
+		ctx.ResponseWriter().Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		r.fail(ctx, err)
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := r.User.CheckCredentials(ctx.Request().Context(), body.Login, body.Password)
+	if err != nil {
+		if failErr := r.Lockout.Fail(ctx.Request().Context(), attempt); failErr != nil {
+			r.log(ctx).Error("cannot record failed attempt", logger.Err(failErr))
+		}
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Lockout.Succeed(ctx.Request().Context(), attempt)
+	if err != nil {
+		r.log(ctx).Error("request failed", logger.Err(err))
+	}
+
+	r.authenticated(ctx, userId)
+}
+
+// Continue login of user who passed the first factor. If two-factor
+// authentication is enabled user gets pending token instead of tokens
+func (r *Repository) authenticated(ctx *rou.Context, userId int) {
+	twoFactorEnabled, err := r.TwoFactor.IsEnabled(ctx.Request().Context(), userId)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
+		return
+	}
+
+	if twoFactorEnabled {
+		pendingToken, err := r.Authorization.CreatePendingToken(ctx.Request().Context(), userId)
+		if err != nil {
+			r.fail(ctx, err)
+			return
+		}
+
+		ctx.SuccessJSONResponse(pendingToken)
 		return
 	}
 
-	userData, err := r.User.Get(2)
+	r.completeLogin(ctx, userId)
+}
+
+// Create tokens for user who passed all of authentication factors
+func (r *Repository) completeLogin(ctx *rou.Context, userId int) {
+	err := r.Authorization.CreateTokens(ctx.Request().Context(), ctx.ResponseWriter(), userId)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
+		return
+	}
+
+	userData, err := r.User.Get(ctx.Request().Context(), userId)
+	if err != nil {
+		r.fail(ctx, err)
 		return
 	}
 
 	ctx.SuccessJSONResponse(userData)
 }
 
-func (r *Repository) CardsList(ctx *rou.Context) {
-	accessToken, err := r.Authorization.GetTokenFromCookie(ctx.Request(), auth.KeyAccessToken)
+func (r *Repository) TokenRefresh(ctx *rou.Context) {
+	var body auth.RefreshTokenRequest
+	err := validate.Decode(ctx.Request(), &body)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
 
-	accessDetails, err := r.Authorization.ExtractAccessMetaData(accessToken)
+	refreshToken, err := r.Authorization.ParseToken(body.RefreshToken)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	tokens, _, err := r.Authorization.RefreshTokenPair(ctx.Request().Context(), refreshToken)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(auth.NewTokensResponse(tokens))
+}
+
+// Principal of request which was resolved by Middleware.AuthorizedUser,
+// so token is not parsed and checked in Redis again
+func (r *Repository) accessDetails(ctx *rou.Context) (auth.AccessDetails, error) {
+	accessDetails, ok := auth.FromContext(ctx.Request().Context())
+	if !ok {
+		return nil, auth.ErrNoAccessDetails
+	}
+	return accessDetails, nil
+}
+
+func (r *Repository) CardsList(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
 
-	card, err := r.Cards.GetCards(accessDetails.GetUserId())
+	card, err := r.Cards.GetCards(ctx.Request().Context(), accessDetails.GetUserId())
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
 	ctx.SuccessJSONResponse(card)
 }
 
-func (r *Repository) UserInfo(ctx *rou.Context) {
-	accessToken, err := r.Authorization.GetTokenFromCookie(ctx.Request(), auth.KeyAccessToken)
+func (r *Repository) CardReveal(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
 
-	accessDetails, err := r.Authorization.ExtractAccessMetaData(accessToken)
+	cardId, err := utils.UUIDParam(ctx, "id")
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
-	user, err := r.User.Get(accessDetails.GetUserId())
 
+	secret, err := r.Cards.Reveal(ctx.Request().Context(), accessDetails.GetUserId(), cardId)
 	if err != nil {
-		r.channels.err <- err
-		ctx.ErrorJSONResponse(http.StatusBadRequest, err.Error())
+		r.fail(ctx, err)
 		return
 	}
 
-	ctx.SuccessJSONResponse(user)
+	ctx.SuccessJSONResponse(secret)
 }
 
-func catchLogs(channels ChannelsList, logger logger.LoggerWriter) {
-	for {
-		select {
-		case msg := <-channels.info:
-			logger.Info(msg)
-		case msg := <-channels.err:
-			logger.Error(msg.Error())
-		case msg := <-channels.warn:
-			logger.Warning(msg)
-		}
+func (r *Repository) UserInfo(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
 	}
-}
+	user, err := r.User.Get(ctx.Request().Context(), accessDetails.GetUserId())
 
-var redisContext = context.Background()
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(user)
+}
 
 func main() {
+	configPath, ok := os.LookupEnv("BILLING_CONFIG_PATH")
+	if !ok {
+		configPath = "./config.yml"
+	}
+	appConfig, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	router := rou.NewRouter()
-	conn, err := sqlx.Connect("postgres", "user=root password=123456 dbname=billing sslmode=disable")
+	// Connection is checked when application starts. Every query made
+	// with context of traced request gets its own span
+	connector, err := pq.NewConnector(appConfig.Postgres.DSN())
 	if err != nil {
 		log.Fatal(err)
 	}
+	conn := sqlx.NewDb(sql.OpenDB(tracing.WrapConnector(connector)), "postgres")
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		Addr:     appConfig.Redis.Addr,
+		Password: appConfig.Redis.Password,
+		DB:       appConfig.Redis.DB,
 	})
+	redisClient.AddHook(tracing.RedisHook{})
+	redisClient.AddHook(metrics.RedisHook{})
 
-	localLogger := logger.NewLogger()
+	localLogger, err := logger.NewLogger(appConfig.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	channels := ChannelsList{
-		info: make(chan string),
-		err:  make(chan error),
-		warn: make(chan string),
+	// Spans are not recorded at all when exporter is not configured
+	exporter, err := tracing.NewExporter(appConfig.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tracer *tracing.Tracer
+	if exporter != nil {
+		tracer = tracing.NewTracer(tracing.TracerSettings{
+			Exporter:    exporter,
+			SampleRatio: appConfig.Tracing.SampleRatio,
+			OnError: func(err error) {
+				localLogger.Warning("cannot export spans", logger.Err(err))
+			},
+		})
+		tracing.SetTracer(tracer)
 	}
 
+	apiKeysService := apikeys.NewService(conn)
 	authorization := auth.NewService(auth.AuthSettings{
-		Db:           conn,
-		Secret:       "secret phrase",
-		RedisClient:  redisClient,
-		RedisContext: redisContext,
+		Db:          conn,
+		Secret:      appConfig.Auth.Secret,
+		RedisClient: redisClient,
+		APIKeys:     apiKeysService,
 	})
 	queryTest := utils.NewQuery(conn)
-	middleware := services.NewMiddlewareService(conn, authorization)
+	middleware := services.NewMiddlewareService(services.MiddlewareSettings{
+		Db:      conn,
+		Auth:    authorization,
+		Logger:  localLogger,
+		Origins: utils.NewOrigins(appConfig.CORS.AllowedOrigins),
+		Limiter: ratelimit.NewService(ratelimit.LimiterSettings{
+			RedisClient: redisClient,
+			Config:      appConfig.RateLimit,
+			OnFallback: func(err error) {
+				localLogger.Warning("rate limits are counted in memory", logger.Err(err))
+			},
+		}),
+	})
+	smsSender, err := notifier.NewSMSSender(appConfig.Notifier, localLogger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	notificationSender, err := notifier.NewNotifier(appConfig.Notifier, localLogger)
+	if err != nil {
+		log.Fatal(err)
+	}
 	cardsService := cards.NewService(conn)
 	userService := user.NewService(conn, queryTest)
+	auditService := audit.NewService(conn)
+	adminService := admin.NewService(conn, auditService)
+	healthService := health.NewService(health.HealthSettings{
+		Db:          conn,
+		RedisClient: redisClient,
+	})
 
 	repository := Repository{
 		Authorization: authorization,
 		Cards:         cardsService,
 		User:          userService,
-		logger:        localLogger,
-		channels:      channels,
+		Admin:         adminService,
+		TwoFactor: twofactor.NewService(twofactor.TwoFactorSettings{
+			Db:          conn,
+			RedisClient: redisClient,
+		}),
+		Password: password.NewService(password.PasswordSettings{
+			RedisClient: redisClient,
+			Secret:      appConfig.Auth.Secret,
+		}),
+		Notifier: notificationSender,
+		OAuth:    oauth.NewService(conn),
+		Audit:    auditService,
+		APIKeys:  apiKeysService,
+		Health:   healthService,
+		Lockout: lockout.NewService(lockout.LockoutSettings{
+			Db:          conn,
+			RedisClient: redisClient,
+			Config:      appConfig.Lockout,
+		}),
+		OTP: otp.NewService(otp.OTPSettings{
+			RedisClient: redisClient,
+			Secret:      appConfig.Auth.Secret,
+			Sender:      smsSender,
+		}),
+		logger: localLogger,
+		spec:   apiSpec(),
+	}
+
+	metrics.Default.Register(
+		metrics.DBStatsCollector(conn.DB),
+		transactionsCollector(cardsService, localLogger),
+	)
+
+	repository.routesRoot(utils.NewRouteGroup(router, ""))
+
+	// Limit of IP is checked before authentication. Limits of routes are
+	// checked after middlewares of route, so requests of authorized
+	// principal are counted together
+	v1Routes := utils.NewRouteGroup(router, API_V1, middleware.RateLimitIP).
+		BeforeHandler(middleware.RateLimit(API_V1))
+	repository.routesV1(v1Routes, middleware)
+	// Routes without version are kept for existing clients until sunset
+	unversionedRoutes := utils.NewRouteGroup(router, "", utils.Deprecated(unversionedDeprecation), middleware.RateLimitIP).
+		BeforeHandler(middleware.RateLimit(""))
+	repository.routesV1(unversionedRoutes, middleware)
+
+	var handler http.Handler = utils.RouterErrors(router)
+	if appConfig.OpenAPI.CheckResponses {
+		handler = openapi.CheckResponses(repository.spec, func(r *http.Request, err error) {
+			localLogger.WithContext(r.Context()).Warning("response does not match specification", logger.Err(err))
+		}, handler)
+	}
+
+	// Address of client is resolved before anything logs or counts it
+	trustedProxies, err := utils.NewTrustedProxies(appConfig.Server.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{
+		Addr:    appConfig.Server.Addr,
+		Handler: utils.RealIP(trustedProxies, utils.AccessLog(localLogger, utils.HTTPTracing(utils.HTTPMetrics(utils.CORS(appConfig.CORS, handler))))),
+	}
+	serverErr := make(chan error, 1)
+
+	// Components are started in order of registration and stopped in
+	// reverse order, so server stops accepting requests first and logger
+	// is closed last
+	app := lifecycle.New()
+	app.Append(lifecycle.Hook{
+		Name: "logger",
+		Stop: func(ctx context.Context) error {
+			closed := make(chan error, 1)
+			go func() {
+				closed <- localLogger.Close()
+			}()
+
+			select {
+			case err := <-closed:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+	if tracer != nil {
+		app.Append(lifecycle.Hook{
+			Name: "tracer",
+			Stop: tracer.Shutdown,
+		})
 	}
+	app.Append(lifecycle.Hook{
+		Name: "postgres",
+		Start: func(ctx context.Context) error {
+			return conn.PingContext(ctx)
+		},
+		Stop: func(ctx context.Context) error {
+			return conn.Close()
+		},
+	})
+	app.Append(lifecycle.Hook{
+		Name: "redis",
+		Start: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		},
+		Stop: func(ctx context.Context) error {
+			return redisClient.Close()
+		},
+	})
+	app.Append(lifecycle.Hook{
+		Name: "http server",
+		Start: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				err := server.Serve(listener)
+				if !errors.Is(err, http.ErrServerClosed) {
+					serverErr <- err
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			// Waits for in-flight handlers to finish
+			return server.Shutdown(ctx)
+		},
+	})
+	app.Append(lifecycle.Hook{
+		Name: "health",
+		Stop: func(ctx context.Context) error {
+			// Readiness fails before server stops accepting requests,
+			// so load balancer has time to remove instance
+			healthService.ShuttingDown()
+			select {
+			case <-time.After(appConfig.Server.DrainDelay):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
 
-	go catchLogs(channels, localLogger)
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
 
-	router.Get("/login", repository.Login)
-	router.Get("/user", repository.UserInfo).Middleware(middleware.AuthorizedUser)
-	router.Get("/cards", repository.CardsList).Middleware(middleware.AuthorizedUser)
+	startCtx, cancelStart := context.WithTimeout(signalCtx, appConfig.Server.ShutdownTimeout)
+	err = app.Start(startCtx)
+	cancelStart()
+	if err != nil {
+		log.Fatal(err)
+	}
+	localLogger.Info("server is listening", logger.String("addr", server.Addr))
 
-	log.Fatal(router.RunServer(":8080"))
+	select {
+	case <-signalCtx.Done():
+		localLogger.Info("shutting down")
+	case err := <-serverErr:
+		localLogger.Error("server has stopped", logger.Err(err))
+	}
+	// Second signal kills application immediately
+	stopSignals()
 
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), appConfig.Server.ShutdownTimeout)
+	defer cancelStop()
+	err = app.Stop(stopCtx)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/metrics"
+	"github.com/Moranilt/billing/services/cards"
+	"github.com/Moranilt/rou"
+)
+
+// Scrape should not hang on slow database
+const TRANSACTIONS_COLLECT_TIMEOUT = time.Second * 5
+
+func (r *Repository) Metrics(ctx *rou.Context) {
+	w := ctx.ResponseWriter()
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	err := metrics.Default.Write(w)
+	if err != nil {
+		r.log(ctx).Error("cannot write metrics", logger.Err(err))
+	}
+}
+
+// Volume of transactions is read from database on every scrape. Metrics
+// are skipped if query fails
+func transactionsCollector(cardsService cards.CardsMethods, log logger.LoggerWriter) metrics.Collector {
+	return metrics.CollectorFunc(func() []metrics.Family {
+		ctx, cancel := context.WithTimeout(context.Background(), TRANSACTIONS_COLLECT_TIMEOUT)
+		defer cancel()
+
+		volume, err := cardsService.TransactionVolume(ctx)
+		if err != nil {
+			log.Error("cannot collect volume of transactions", logger.Err(err))
+			return nil
+		}
+
+		count := metrics.Family{
+			Name: "billing_transactions",
+			Help: "Number of transactions by state.",
+			Type: metrics.TypeGauge,
+		}
+		amount := metrics.Family{
+			Name: "billing_transactions_amount",
+			Help: "Total amount of transactions by state.",
+			Type: metrics.TypeGauge,
+		}
+		for _, state := range volume {
+			labels := []metrics.Label{{Name: "state", Value: state.State}}
+			count.Samples = append(count.Samples, metrics.Sample{Labels: labels, Value: float64(state.Count)})
+			amount.Samples = append(amount.Samples, metrics.Sample{Labels: labels, Value: state.Amount})
+		}
+		return []metrics.Family{count, amount}
+	})
+}
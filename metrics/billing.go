@@ -0,0 +1,74 @@
+package metrics
+
+const (
+	TokenSession       = "session"
+	TokenImpersonation = "impersonation"
+	TokenClient        = "client"
+
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+
+	// Route label of requests which did not match any route, so unknown
+	// paths do not create new series
+	RouteUnmatched = "unmatched"
+)
+
+var (
+	HTTPRequests = NewCounterVec(
+		"billing_http_requests_total",
+		"Number of HTTP requests by route, method and status.",
+		"route", "method", "status",
+	)
+	HTTPRequestDuration = NewHistogramVec(
+		"billing_http_request_duration_seconds",
+		"Latency of HTTP requests by route and method.",
+		DefaultBuckets,
+		"route", "method",
+	)
+
+	RedisCommandDuration = NewHistogramVec(
+		"billing_redis_command_duration_seconds",
+		"Latency of Redis commands by name of command.",
+		DefaultBuckets,
+		"command",
+	)
+	RedisCommandErrors = NewCounterVec(
+		"billing_redis_command_errors_total",
+		"Number of failed Redis commands by name of command.",
+		"command",
+	)
+
+	TokensIssued = NewCounterVec(
+		"billing_auth_tokens_issued_total",
+		"Number of issued tokens by type: session, impersonation or client.",
+		"type",
+	)
+	TokensRefreshed = NewCounterVec(
+		"billing_auth_tokens_refreshed_total",
+		"Number of refreshed token pairs.",
+	)
+	TokenFailures = NewCounterVec(
+		"billing_auth_token_failures_total",
+		"Number of rejected tokens by type: access or refresh.",
+		"type",
+	)
+
+	CardsIssued = NewCounterVec(
+		"billing_cards_issued_total",
+		"Number of issued cards.",
+	)
+)
+
+func init() {
+	Default.Register(
+		HTTPRequests,
+		HTTPRequestDuration,
+		RedisCommandDuration,
+		RedisCommandErrors,
+		TokensIssued,
+		TokensRefreshed,
+		TokenFailures,
+		CardsIssued,
+		RuntimeCollector(),
+	)
+}
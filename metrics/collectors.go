@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"time"
+)
+
+// Family with one sample without labels
+func Single(name string, help string, metricType string, value float64) Family {
+	return Family{
+		Name:    name,
+		Help:    help,
+		Type:    metricType,
+		Samples: []Sample{{Value: value}},
+	}
+}
+
+// Goroutines, memory and garbage collector of process
+func RuntimeCollector() Collector {
+	start := time.Now()
+	return CollectorFunc(func() []Family {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		return []Family{
+			Single("go_goroutines", "Number of goroutines.", TypeGauge, float64(runtime.NumGoroutine())),
+			Single("go_memstats_alloc_bytes", "Bytes of allocated heap objects.", TypeGauge, float64(mem.Alloc)),
+			Single("go_memstats_sys_bytes", "Bytes of memory obtained from system.", TypeGauge, float64(mem.Sys)),
+			Single("go_memstats_heap_objects", "Number of allocated heap objects.", TypeGauge, float64(mem.HeapObjects)),
+			Single("go_gc_cycles_total", "Number of completed GC cycles.", TypeCounter, float64(mem.NumGC)),
+			Single("process_uptime_seconds", "Time since process has started.", TypeGauge, time.Since(start).Seconds()),
+		}
+	})
+}
+
+// Stats of connection pool of database
+func DBStatsCollector(db *sql.DB) Collector {
+	return CollectorFunc(func() []Family {
+		stats := db.Stats()
+
+		return []Family{
+			Single("billing_db_max_open_connections", "Maximum number of open connections.", TypeGauge, float64(stats.MaxOpenConnections)),
+			Single("billing_db_open_connections", "Number of open connections.", TypeGauge, float64(stats.OpenConnections)),
+			Single("billing_db_in_use_connections", "Number of connections in use.", TypeGauge, float64(stats.InUse)),
+			Single("billing_db_idle_connections", "Number of idle connections.", TypeGauge, float64(stats.Idle)),
+			Single("billing_db_wait_count_total", "Number of connections waited for.", TypeCounter, float64(stats.WaitCount)),
+			Single("billing_db_wait_duration_seconds_total", "Time blocked waiting for new connections.", TypeCounter, stats.WaitDuration.Seconds()),
+			Single("billing_db_max_idle_closed_total", "Number of connections closed due to max idle connections.", TypeCounter, float64(stats.MaxIdleClosed)),
+			Single("billing_db_max_idle_time_closed_total", "Number of connections closed due to max idle time.", TypeCounter, float64(stats.MaxIdleTimeClosed)),
+			Single("billing_db_max_lifetime_closed_total", "Number of connections closed due to max lifetime.", TypeCounter, float64(stats.MaxLifetimeClosed)),
+		}
+	})
+}
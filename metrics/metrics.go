@@ -0,0 +1,306 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+
+	// Content type of Prometheus text format
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Buckets in seconds which fit latency of HTTP requests and queries
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Sample struct {
+	// Suffix of metric name, e.g. _bucket for histograms
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// All samples of one metric
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+type Collector interface {
+	Collect() []Family
+}
+
+// Collector which builds families when metrics are requested, e.g. from
+// stats of connection pool
+type CollectorFunc func() []Family
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Registry which is used by metrics of this package and /metrics route
+var Default = NewRegistry()
+
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Write all metrics in Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, collector := range collectors {
+		for _, family := range collector.Collect() {
+			writeFamily(buf, family)
+		}
+	}
+	return buf.Flush()
+}
+
+func writeFamily(w *bufio.Writer, family Family) {
+	fmt.Fprintf(w, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.Name, family.Type)
+	for _, sample := range family.Samples {
+		w.WriteString(family.Name)
+		w.WriteString(sample.Suffix)
+		if len(sample.Labels) > 0 {
+			w.WriteByte('{')
+			for i, label := range sample.Labels {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				w.WriteString(label.Name)
+				w.WriteString(`="`)
+				w.WriteString(escapeLabel(label.Value))
+				w.WriteByte('"')
+			}
+			w.WriteByte('}')
+		}
+		w.WriteByte(' ')
+		w.WriteString(formatValue(sample.Value))
+		w.WriteByte('\n')
+	}
+}
+
+func escapeHelp(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func labelPairs(names []string, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+	return labels
+}
+
+// Values of labels joined to one key of map
+func labelsKey(names []string, values []string) string {
+	if len(values) != len(names) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(names), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter with labels. Values of labels are passed in order of names
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Counter without labels is reported as zero until it is incremented
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: make(map[string]*counterSeries),
+	}
+	if len(labels) == 0 {
+		counter.series[""] = &counterSeries{}
+	}
+	return counter
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := labelsKey(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{values: append([]string(nil), labelValues...)}
+		c.series[key] = series
+	}
+	series.value += value
+}
+
+func (c *CounterVec) Collect() []Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range sortedKeys(c.series) {
+		series := c.series[key]
+		family.Samples = append(family.Samples, Sample{
+			Labels: labelPairs(c.labels, series.values),
+			Value:  series.value,
+		})
+	}
+	return []Family{family}
+}
+
+// Histogram with labels. Buckets are upper bounds in ascending order
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := labelsKey(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{
+			values: append([]string(nil), labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = series
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+			break
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func (h *HistogramVec) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		labels := labelPairs(h.labels, series.values)
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			family.Samples = append(family.Samples, Sample{
+				Suffix: "_bucket",
+				Labels: append(append([]Label(nil), labels...), Label{Name: "le", Value: formatValue(bound)}),
+				Value:  float64(cumulative),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{
+				Suffix: "_bucket",
+				Labels: append(append([]Label(nil), labels...), Label{Name: "le", Value: "+Inf"}),
+				Value:  float64(series.count),
+			},
+			Sample{Suffix: "_sum", Labels: labels, Value: series.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(series.count)},
+		)
+	}
+	return []Family{family}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStartKey struct{}
+
+// Hook of redis client which observes latency and errors of commands.
+// Pipelines are observed as one command with name "pipeline"
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && !errors.Is(cmd.Err(), redis.Nil) {
+			err = cmd.Err()
+			break
+		}
+	}
+	observeRedis(ctx, "pipeline", err)
+	return nil
+}
+
+// Missing key is not an error of Redis
+func observeRedis(ctx context.Context, command string, err error) {
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if ok {
+		RedisCommandDuration.Observe(time.Since(start).Seconds(), command)
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		RedisCommandErrors.Inc(command)
+	}
+}
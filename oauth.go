@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/services/audit"
+	"github.com/Moranilt/billing/services/oauth"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+// Token endpoint of OAuth2 client credentials grant. Request and response
+// follow RFC 6749 instead of common response structure, so any of OAuth2
+// client libraries can be used
+func (r *Repository) OAuthToken(ctx *rou.Context) {
+	request := ctx.Request()
+	err := request.ParseForm()
+	if err != nil {
+		writeOAuthError(ctx, http.StatusBadRequest, oauth.ErrorInvalidRequest, rou.MessageBodyIsNotValid)
+		return
+	}
+
+	form := oauth.TokenRequest{
+		GrantType:    request.PostForm.Get("grant_type"),
+		ClientId:     request.PostForm.Get("client_id"),
+		ClientSecret: request.PostForm.Get("client_secret"),
+		Scope:        request.PostForm.Get("scope"),
+	}
+	if form.GrantType != oauth.GrantTypeClientCredentials {
+		writeOAuthError(ctx, http.StatusBadRequest, oauth.ErrorUnsupportedGrantType, "")
+		return
+	}
+
+	clientId, clientSecret, basicAuth := request.BasicAuth()
+	if !basicAuth {
+		clientId = form.ClientId
+		clientSecret = form.ClientSecret
+	}
+
+	client, err := r.OAuth.Authenticate(ctx.Request().Context(), clientId, clientSecret)
+	if errors.Is(err, oauth.ErrNotValidClient) || errors.Is(err, oauth.ErrCredentialsMissing) {
+		if basicAuth {
+			ctx.ResponseWriter().Header().Set("WWW-Authenticate", `Basic realm="billing"`)
+		}
+		writeOAuthError(ctx, http.StatusUnauthorized, oauth.ErrorInvalidClient, err.Error())
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	scopes, err := r.OAuth.GrantScopes(client, form.Scope)
+	if err != nil {
+		writeOAuthError(ctx, http.StatusBadRequest, oauth.ErrorInvalidScope, err.Error())
+		return
+	}
+
+	token, err := r.Authorization.CreateClientToken(ctx.Request().Context(), client.ClientId, scopes)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	writeOAuthJSON(ctx, http.StatusOK, token)
+}
+
+func (r *Repository) AdminCreateOAuthClient(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body oauth.ClientCreate
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	credentials, err := r.OAuth.Create(ctx.Request().Context(), body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Audit.Record(ctx.Request().Context(), audit.Entry{
+		ActorId:    accessDetails.GetUserId(),
+		Action:     audit.ActionOAuthClientsCreate,
+		TargetType: audit.TargetOAuthClient,
+		TargetId:   credentials.ClientId,
+		Details:    map[string]any{"name": credentials.Name, "scopes": credentials.Scopes},
+	})
+	if err != nil {
+		r.log(ctx).Error("request failed", logger.Err(err))
+	}
+
+	ctx.SuccessJSONResponse(credentials)
+}
+
+func writeOAuthError(ctx *rou.Context, status int, code string, description string) {
+	writeOAuthJSON(ctx, status, oauth.TokenError{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+func writeOAuthJSON(ctx *rou.Context, status int, body any) {
+	w := ctx.ResponseWriter()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/metrics"
+	"github.com/Moranilt/billing/openapi"
+	"github.com/Moranilt/billing/services/admin"
+	"github.com/Moranilt/billing/services/apikeys"
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/services/cards"
+	"github.com/Moranilt/billing/services/health"
+	"github.com/Moranilt/billing/services/lockout"
+	"github.com/Moranilt/billing/services/oauth"
+	"github.com/Moranilt/billing/services/otp"
+	"github.com/Moranilt/billing/services/password"
+	"github.com/Moranilt/billing/services/ratelimit"
+	"github.com/Moranilt/billing/services/twofactor"
+	"github.com/Moranilt/billing/services/user"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/rou"
+)
+
+// Errors of Middleware.AuthorizedUser and RequirePermission
+var authorizationErrors = []*errs.Error{
+	auth.ErrEmptyToken,
+	auth.ErrNotValidToken,
+	auth.ErrNotValidAuthHeader,
+	auth.ErrPermissionDenied,
+	auth.ErrReadOnlySession,
+	auth.ErrOriginNotAllowed,
+	apikeys.ErrNotValidKey,
+	apikeys.ErrIPNotAllowed,
+}
+
+// Errors of routes which are allowed only for sessions of users
+var sessionErrors = append([]*errs.Error{auth.ErrSessionRequired}, authorizationErrors...)
+
+// Errors of verification of code of two-factor authentication
+var twoFactorCodeErrors = []*errs.Error{
+	twofactor.ErrNotEnabled,
+	twofactor.ErrNotValidCode,
+	twofactor.ErrCodeAlreadyUsed,
+	twofactor.ErrTooManyAttempts,
+}
+
+// Errors of verification of one-time code sent to phone
+var otpErrors = []*errs.Error{
+	otp.ErrCodeExpired,
+	otp.ErrNotValidCode,
+	otp.ErrTooManyAttempts,
+}
+
+func withErrors(groups ...[]*errs.Error) []*errs.Error {
+	var result []*errs.Error
+	for _, group := range groups {
+		result = append(result, group...)
+	}
+	return result
+}
+
+// Specification of routes. Schemas are built from models which
+// handlers decode and respond with, so they change together with models
+func apiSpec() *openapi.Document {
+	spec := openapi.NewDocument(openapi.Info{
+		Title:       "billing",
+		Description: "Accounts and payment cards of users",
+		Version:     "1.0.0",
+	})
+	// every route of API is limited by default or its own policy
+	add := func(route openapi.Route) {
+		route.Errors = withErrors(route.Errors, []*errs.Error{ratelimit.ErrRateLimited})
+		spec.Add(route)
+	}
+
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/login",
+		Summary:  "Log in by login and password. Tokens are sent in cookies, pending token is sent when two-factor authentication is enabled",
+		Tags:     []string{"auth"},
+		Request:  user.Credentials{},
+		Response: openapi.OneOf(user.User{}, auth.PendingTokenResponse{}),
+		Errors: []*errs.Error{
+			user.ErrNotValidCredentials,
+			lockout.ErrAccountLocked,
+			lockout.ErrTooManyAttempts,
+		},
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/login/2fa",
+		Summary:  "Complete login by pending token and code of two-factor authentication",
+		Tags:     []string{"auth"},
+		Request:  auth.PendingTokenRequest{},
+		Response: user.User{},
+		Errors: []*errs.Error{
+			auth.ErrNotValidToken,
+			auth.ErrTooManyAttempts,
+			twofactor.ErrNotValidCode,
+			twofactor.ErrCodeAlreadyUsed,
+			twofactor.ErrTooManyAttempts,
+		},
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/token/refresh",
+		Summary:  "Exchange refresh token for new pair of tokens",
+		Tags:     []string{"auth"},
+		Request:  auth.RefreshTokenRequest{},
+		Response: auth.TokensResponse{},
+		Errors: []*errs.Error{
+			auth.ErrEmptyToken,
+			auth.ErrNotValidToken,
+			auth.ErrNotValidClaims,
+		},
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/user",
+		Summary:  "Profile of user with cards",
+		Tags:     []string{"user"},
+		Response: user.User{},
+		Errors:   authorizationErrors,
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/cards",
+		Summary:  "Cards of user",
+		Tags:     []string{"cards"},
+		Response: []cards.Card{},
+		Errors:   authorizationErrors,
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/cards/:id/reveal",
+		Summary:  "Number and CVC of card. Requires two-factor verification",
+		Tags:     []string{"cards"},
+		Response: cards.CardSecret{},
+		Errors:   append([]*errs.Error{auth.ErrStepUpRequired}, authorizationErrors...),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/login/otp",
+		Summary: "Send one-time code for login to phone. Response is the same for unknown phone",
+		Tags:    []string{"auth"},
+		Request: otp.SendRequest{},
+		Errors:  []*errs.Error{otp.ErrSendTimeout},
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/login/otp/verify",
+		Summary:  "Log in by one-time code. Tokens are sent in cookies, pending token is sent when two-factor authentication is enabled",
+		Tags:     []string{"auth"},
+		Request:  otp.VerifyRequest{},
+		Response: openapi.OneOf(user.User{}, auth.PendingTokenResponse{}),
+		Errors:   otpErrors,
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/login/unlock",
+		Summary: "Send one-time code to phone of locked account. Response is the same for unknown login and account which is not locked",
+		Tags:    []string{"auth"},
+		Request: lockout.UnlockRequest{},
+		Errors:  []*errs.Error{otp.ErrSendTimeout},
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/login/unlock/verify",
+		Summary: "Unlock account by one-time code",
+		Tags:    []string{"auth"},
+		Request: lockout.UnlockVerifyRequest{},
+		Errors:  otpErrors,
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/password/forgot",
+		Summary: "Send token to reset password. Response is the same for unknown login",
+		Tags:    []string{"auth"},
+		Request: password.ForgotRequest{},
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/password/reset",
+		Summary: "Set new password by reset token and revoke sessions of user",
+		Tags:    []string{"auth"},
+		Request: password.ResetRequest{},
+		Errors: []*errs.Error{
+			user.ErrPasswordTooShort,
+			password.ErrNotValidToken,
+		},
+	})
+	add(openapi.Route{
+		Method:             http.MethodPost,
+		Path:               API_V1 + "/oauth/token",
+		Summary:            "Token endpoint of OAuth2 client credentials grant. Request and responses follow RFC 6749",
+		Tags:               []string{"oauth"},
+		Request:            oauth.TokenRequest{},
+		RequestContentType: openapi.ContentTypeForm,
+		Response:           auth.ClientTokenResponse{},
+		ContentType:        openapi.ContentTypeJSON,
+		Responses: map[int]any{
+			http.StatusBadRequest:   oauth.TokenError{},
+			http.StatusUnauthorized: oauth.TokenError{},
+		},
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/api-keys",
+		Summary:  "API keys of user which are not revoked",
+		Tags:     []string{"api-keys"},
+		Response: []apikeys.APIKey{},
+		Errors:   sessionErrors,
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/api-keys",
+		Summary:  "Create API key. Key is returned only once",
+		Tags:     []string{"api-keys"},
+		Request:  apikeys.APIKeyCreate{},
+		Response: apikeys.APIKeyCreated{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			apikeys.ErrEmptyName,
+			apikeys.ErrEmptyScopes,
+			apikeys.ErrScopeNotAllowed,
+			apikeys.ErrNotValidIP,
+		}),
+		Auth: true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/api-keys/:id/rotate",
+		Summary:  "Replace secret of API key. Previous secret stops working immediately",
+		Tags:     []string{"api-keys"},
+		Response: apikeys.APIKeyCreated{},
+		Errors:   withErrors(sessionErrors, []*errs.Error{apikeys.ErrKeyNotFound}),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:  http.MethodDelete,
+		Path:    API_V1 + "/api-keys/:id",
+		Summary: "Revoke API key",
+		Tags:    []string{"api-keys"},
+		Errors:  withErrors(sessionErrors, []*errs.Error{apikeys.ErrKeyNotFound}),
+		Auth:    true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/2fa/enroll",
+		Summary:  "Create TOTP secret and URI for authenticator application",
+		Tags:     []string{"2fa"},
+		Response: twofactor.Enrollment{},
+		Errors:   withErrors(sessionErrors, []*errs.Error{twofactor.ErrAlreadyEnabled}),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/2fa/confirm",
+		Summary:  "Enable two-factor authentication by the first code. Recovery codes are returned only once",
+		Tags:     []string{"2fa"},
+		Request:  twofactor.CodeRequest{},
+		Response: twofactor.RecoveryCodes{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			twofactor.ErrNotEnrolled,
+			twofactor.ErrAlreadyEnabled,
+			twofactor.ErrNotValidCode,
+		}),
+		Auth: true,
+	})
+	add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    API_V1 + "/2fa/disable",
+		Summary: "Disable two-factor authentication by code or recovery code",
+		Tags:    []string{"2fa"},
+		Request: twofactor.CodeRequest{},
+		Errors:  withErrors(sessionErrors, twoFactorCodeErrors),
+		Auth:    true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/2fa/step-up",
+		Summary:  "Verify session by code, so it can access sensitive routes",
+		Tags:     []string{"2fa"},
+		Request:  twofactor.CodeRequest{},
+		Response: auth.StepUpResponse{},
+		Errors:   withErrors(sessionErrors, twoFactorCodeErrors),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/admin/users",
+		Summary:  "Search users by part of email, phone or name",
+		Tags:     []string{"admin"},
+		Query:    []string{"query"},
+		Response: []admin.UserShort{},
+		Errors:   withErrors(sessionErrors, []*errs.Error{admin.ErrEmptySearchQuery}),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     API_V1 + "/admin/users/:id",
+		Summary:  "User with passport and cards",
+		Tags:     []string{"admin"},
+		Response: admin.UserDetails{},
+		Errors:   sessionErrors,
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/admin/users/:id/impersonate",
+		Summary:  "Create read-only access token of user",
+		Tags:     []string{"admin"},
+		Response: auth.ImpersonationResponse{},
+		Errors:   withErrors(sessionErrors, []*errs.Error{auth.ErrCannotImpersonateAdmin}),
+		Auth:     true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/admin/cards/:id/block",
+		Summary:  "Block card",
+		Tags:     []string{"admin"},
+		Request:  admin.CardStateChange{},
+		Response: admin.Card{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			admin.ErrEmptyReason,
+			admin.ErrCardIsOutdated,
+			admin.ErrCardStateNotChanged,
+		}),
+		Auth: true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/admin/cards/:id/unblock",
+		Summary:  "Activate blocked card",
+		Tags:     []string{"admin"},
+		Request:  admin.CardStateChange{},
+		Response: admin.Card{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			admin.ErrEmptyReason,
+			admin.ErrCardIsOutdated,
+			admin.ErrCardIsNotBlocked,
+		}),
+		Auth: true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/admin/cards/:id/balance",
+		Summary:  "Add amount in minor units to balance of card. Negative amount debits card",
+		Tags:     []string{"admin"},
+		Request:  admin.BalanceAdjustment{},
+		Response: admin.Card{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			admin.ErrEmptyReason,
+			admin.ErrZeroAmount,
+			admin.ErrNegativeBalance,
+		}),
+		Auth: true,
+	})
+	add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     API_V1 + "/admin/oauth/clients",
+		Summary:  "Register OAuth2 client. Secret is returned only once",
+		Tags:     []string{"admin"},
+		Request:  oauth.ClientCreate{},
+		Response: oauth.ClientCredentials{},
+		Errors: withErrors(sessionErrors, []*errs.Error{
+			oauth.ErrEmptyName,
+			oauth.ErrNotValidScope,
+		}),
+		Auth: true,
+	})
+
+	// routes of service are not versioned and not limited
+	spec.Add(openapi.Route{
+		Method:   http.MethodGet,
+		Path:     "/healthz",
+		Summary:  "Liveness of process. Dependencies are not checked",
+		Tags:     []string{"service"},
+		Response: health.Report{},
+	})
+	spec.Add(openapi.Route{
+		Method:        http.MethodGet,
+		Path:          "/readyz",
+		Summary:       "Readiness of service and status of every dependency",
+		Tags:          []string{"service"},
+		Response:      health.Report{},
+		Errors:        []*errs.Error{health.ErrNotReady, health.ErrShuttingDown},
+		ErrorResponse: health.Report{},
+	})
+	spec.Add(openapi.Route{
+		Method:      http.MethodGet,
+		Path:        "/metrics",
+		Summary:     "Metrics in Prometheus text format",
+		Tags:        []string{"service"},
+		ContentType: metrics.ContentType,
+	})
+	spec.Add(openapi.Route{
+		Method:      http.MethodGet,
+		Path:        "/openapi.json",
+		Summary:     "This specification",
+		Tags:        []string{"service"},
+		ContentType: openapi.ContentTypeJSON,
+	})
+
+	return spec
+}
+
+func (r *Repository) OpenAPI(ctx *rou.Context) {
+	utils.WriteJSON(ctx.ResponseWriter(), http.StatusOK, r.spec)
+}
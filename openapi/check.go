@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Moranilt/billing/utils"
+)
+
+// Records status and copy of body of response
+type bodyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bodyRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *bodyRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Wraps router to check responses of routes which are described in
+// document, so handlers which drift from specification are found in
+// development and staging. Response is sent to client as is, mismatch
+// is passed to onMismatch. Should be wrapped by AccessLog, which prepares
+// context of request
+func CheckResponses(d *Document, onMismatch func(r *http.Request, err error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &bodyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		route := utils.Route(r.Context())
+		if route == "" {
+			return
+		}
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		err := d.CheckResponse(r.Method, route, status, recorder.body.Bytes())
+		if err != nil {
+			onMismatch(r, err)
+		}
+	})
+}
+
+// Check that response of route matches its operation: status is described
+// and body matches schema of response. Objects of models cannot have
+// properties which are not described. Routes which are not described are
+// not checked
+func (d *Document) CheckResponse(method string, route string, status int, body []byte) error {
+	operation, ok := d.Operation(method, route)
+	if !ok {
+		return nil
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s responded with status %d which is not described", method, route, status)
+	}
+	media, ok := response.Content[ContentTypeJSON]
+	if !ok {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("%s %s responded with body which is not JSON: %w", method, route, err)
+	}
+
+	err = d.check(media.Schema, value, "response")
+	if err != nil {
+		return fmt.Errorf("%s %s responded with %d: %w", method, route, status, err)
+	}
+	return nil
+}
+
+func (d *Document) check(schema *Schema, value any, path string) error {
+	if schema.Ref != "" {
+		component, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, RefPrefix)]
+		if !ok {
+			return fmt.Errorf("%s has unknown schema %s", path, schema.Ref)
+		}
+		return d.check(component, value, path)
+	}
+
+	if value == nil {
+		if schema.Nullable || isAny(schema) {
+			return nil
+		}
+		return fmt.Errorf("%s should not be null", path)
+	}
+
+	for _, item := range schema.AllOf {
+		err := d.check(item, value, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(schema.OneOf) > 0 {
+		matched := 0
+		for _, item := range schema.OneOf {
+			if d.check(item, value, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s matches %d of %d schemas instead of one", path, matched, len(schema.OneOf))
+		}
+	}
+
+	switch schema.Type {
+	case TypeObject:
+		return d.checkObject(schema, value, path)
+	case TypeArray:
+		list, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s should be array", path)
+		}
+		for i, item := range list {
+			err := d.check(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s should be string", path)
+		}
+		if len(schema.Enum) > 0 && !inEnum(schema.Enum, text) {
+			return fmt.Errorf("%s has value %q which is not in enum", path, text)
+		}
+	case TypeInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s should be integer", path)
+		}
+		if _, err := number.Int64(); err != nil {
+			return fmt.Errorf("%s should be integer", path)
+		}
+	case TypeNumber:
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%s should be number", path)
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s should be boolean", path)
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) checkObject(schema *Schema, value any, path string) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s should be object", path)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s.%s is required", path, name)
+		}
+	}
+
+	for name, property := range object {
+		propertySchema, ok := schema.Properties[name]
+		if !ok {
+			propertySchema = schema.AdditionalProperties
+		}
+		if propertySchema == nil && schema.Properties == nil {
+			continue
+		}
+		if propertySchema == nil {
+			return fmt.Errorf("%s.%s is not described", path, name)
+		}
+
+		err := d.check(propertySchema, property, path+"."+name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Schema without type and references matches any value
+func isAny(schema *Schema) bool {
+	return schema.Type == "" && schema.Ref == "" && len(schema.AllOf) == 0 && len(schema.OneOf) == 0
+}
+
+func inEnum(enum []any, value string) bool {
+	for _, item := range enum {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+type checkedItem struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"`
+	Note  *string `json:"note"`
+	Kind  string  `json:"kind" validate:"oneof=a b"`
+}
+
+func checkedDocument() *Document {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	d.Add(Route{
+		Method:   http.MethodGet,
+		Path:     "/items/:id",
+		Response: []checkedItem{},
+		Errors:   []*errs.Error{errs.ErrValidation},
+	})
+	return d
+}
+
+func TestCheckResponse(t *testing.T) {
+	d := checkedDocument()
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		valid  bool
+	}{
+		{
+			name:   "described body",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":[{"name":"a","count":1,"note":null,"kind":"a"}]}`,
+			valid:  true,
+		},
+		{
+			name:   "described error",
+			status: http.StatusNotFound,
+			body:   `{"error":{"message":"not found","code":404,"reason":"not_found","request_id":"1"},"body":null}`,
+			valid:  true,
+		},
+		{
+			name:   "field which is not described",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":[{"name":"a","count":1,"note":null,"kind":"a","secret":"x"}]}`,
+		},
+		{
+			name:   "missing required field",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":[{"name":"a","note":null,"kind":"a"}]}`,
+		},
+		{
+			name:   "wrong type",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":[{"name":"a","count":"1","note":null,"kind":"a"}]}`,
+		},
+		{
+			name:   "value which is not in enum",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":[{"name":"a","count":1,"note":null,"kind":"c"}]}`,
+		},
+		{
+			name:   "status which is not described",
+			status: http.StatusConflict,
+			body:   `{"error":{"message":"conflict","code":409,"reason":"conflict","request_id":"1"},"body":null}`,
+		},
+		{
+			name:   "body which is not JSON",
+			status: http.StatusOK,
+			body:   `ok`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := d.CheckResponse(http.MethodGet, "/items/:id", test.status, []byte(test.body))
+			if test.valid && err != nil {
+				t.Fatalf("expected body to match specification, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected mismatch")
+			}
+		})
+	}
+}
+
+func TestCheckResponseOfRouteWhichIsNotDescribed(t *testing.T) {
+	err := checkedDocument().CheckResponse(http.MethodPost, "/items/:id", http.StatusOK, []byte(`ok`))
+	if err != nil {
+		t.Fatalf("expected routes which are not described to be skipped, got %v", err)
+	}
+}
+
+func TestCheckResponseWhichIsNotWrapped(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	d.Add(Route{
+		Method:             http.MethodPost,
+		Path:               "/token",
+		Request:            checkedItem{},
+		RequestContentType: ContentTypeForm,
+		Response:           checkedItem{},
+		ContentType:        ContentTypeJSON,
+		Responses:          map[int]any{http.StatusBadRequest: checkedItem{}},
+	})
+	d.Add(Route{
+		Method:        http.MethodGet,
+		Path:          "/ready",
+		Response:      checkedItem{},
+		Errors:        []*errs.Error{errs.New(http.StatusServiceUnavailable, "not_ready", "not ready")},
+		ErrorResponse: checkedItem{},
+	})
+	d.Add(Route{
+		Method:      http.MethodGet,
+		Path:        "/metrics",
+		ContentType: "text/plain",
+	})
+
+	item := `{"name":"a","count":1,"note":null,"kind":"a"}`
+	tests := []struct {
+		name   string
+		method string
+		route  string
+		status int
+		body   string
+		valid  bool
+	}{
+		{
+			name:   "response as is",
+			method: http.MethodPost,
+			route:  "/token",
+			status: http.StatusOK,
+			body:   item,
+			valid:  true,
+		},
+		{
+			name:   "wrapped response instead of response as is",
+			method: http.MethodPost,
+			route:  "/token",
+			status: http.StatusOK,
+			body:   `{"error":null,"body":` + item + `}`,
+		},
+		{
+			name:   "described response by status",
+			method: http.MethodPost,
+			route:  "/token",
+			status: http.StatusBadRequest,
+			body:   item,
+			valid:  true,
+		},
+		{
+			name:   "error with body",
+			method: http.MethodGet,
+			route:  "/ready",
+			status: http.StatusServiceUnavailable,
+			body:   `{"error":{"message":"not ready","code":503,"reason":"not_ready","request_id":"1"},"body":` + item + `}`,
+			valid:  true,
+		},
+		{
+			name:   "error without described body",
+			method: http.MethodGet,
+			route:  "/ready",
+			status: http.StatusServiceUnavailable,
+			body:   `{"error":{"message":"not ready","code":503,"reason":"not_ready","request_id":"1"},"body":{"secret":"x"}}`,
+		},
+		{
+			name:   "response which is not JSON",
+			method: http.MethodGet,
+			route:  "/metrics",
+			status: http.StatusOK,
+			body:   `metric 1`,
+			valid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := d.CheckResponse(test.method, test.route, test.status, []byte(test.body))
+			if test.valid && err != nil {
+				t.Fatalf("expected body to match specification, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected mismatch")
+			}
+		})
+	}
+}
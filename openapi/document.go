@@ -0,0 +1,316 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/utils"
+)
+
+const (
+	Version = "3.0.3"
+
+	ContentTypeJSON = "application/json"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+
+	// Names of security schemes of document
+	SecurityBearer = "bearer"
+	SecurityCookie = "cookie"
+	SecurityAPIKey = "api_key"
+)
+
+// Params of route in path of router, e.g. :id in /cards/:id/reveal
+var routeParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	// Operations by method and pattern of router, used to check responses
+	routes map[string]*Operation
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Operations of one path by lowercase method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Route of router which is described in document
+type Route struct {
+	Method string
+	// Pattern of router, e.g. /cards/:id/reveal
+	Path    string
+	Summary string
+	Tags    []string
+	// Names of parameters of query string which route reads
+	Query []string
+	// Model of request body, nil if route does not accept body
+	Request any
+	// Content type of request body, JSON by default. Body of other type
+	// is described by schema of model too, e.g. fields of form
+	RequestContentType string
+	// Model of body of successful response, see OneOf for routes which
+	// respond with different models
+	Response any
+	// Content type of response which is sent as is instead of common
+	// response, e.g. metrics or token of OAuth2. Response is described
+	// by schema only if it is JSON
+	ContentType string
+	// Errors which route can respond with besides common ones
+	Errors []*errs.Error
+	// Model of body which is sent together with errors, e.g. report of
+	// readiness. Body of errors is null by default
+	ErrorResponse any
+	// Models of responses by status which are sent as is instead of
+	// common response, e.g. errors of OAuth2
+	Responses map[int]any
+	// Route requires token of user, API key or session cookie
+	Auth bool
+}
+
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				SecurityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				SecurityCookie: {Type: "apiKey", In: "cookie", Name: auth.KeyAccessToken},
+				SecurityAPIKey: {Type: "apiKey", In: "header", Name: auth.KeyAPIKeyHeader},
+			},
+		},
+		routes: make(map[string]*Operation),
+	}
+}
+
+// Add operation of route. Responses are wrapped in common response of
+// application: body is sent in "body" and errors in "error"
+func (d *Document) Add(route Route) {
+	path := routeParamPattern.ReplaceAllString(route.Path, "{$1}")
+	operation := &Operation{
+		OperationId: operationId(route.Method, route.Path),
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Responses: map[string]Response{
+			strconv.Itoa(http.StatusOK): {
+				Description: "Successful response",
+				Content:     d.responseContent(route),
+			},
+		},
+	}
+
+	hasPathParams := false
+	for _, param := range routeParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		hasPathParams = true
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     param[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: TypeString},
+		})
+	}
+	for _, name := range route.Query {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:   name,
+			In:     "query",
+			Schema: &Schema{Type: TypeString},
+		})
+	}
+
+	// Errors which are common for routes of one kind
+	routeErrors := []*errs.Error{errs.ErrInternal}
+	if route.Request != nil {
+		contentType := route.RequestContentType
+		if contentType == "" {
+			contentType = ContentTypeJSON
+			// errors of decoding and validation of JSON body
+			routeErrors = append(routeErrors, errs.ErrBodyNotValid, errs.ErrBodyTooLarge, errs.ErrValidation)
+		}
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentType: {Schema: d.Schema(route.Request)}},
+		}
+	}
+	if hasPathParams {
+		routeErrors = append(routeErrors, errs.ErrNotFound)
+	}
+	if route.Auth {
+		operation.Security = []map[string][]string{
+			{SecurityBearer: {}},
+			{SecurityCookie: {}},
+			{SecurityAPIKey: {}},
+		}
+	}
+	routeErrors = append(routeErrors, route.Errors...)
+	var errorBody *Schema
+	if route.ErrorResponse != nil {
+		errorBody = d.Schema(route.ErrorResponse)
+	}
+	d.addErrors(operation, routeErrors, errorBody)
+
+	for status, model := range route.Responses {
+		operation.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     jsonContent(d.Schema(model)),
+		}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(route.Method)] = operation
+	d.routes[routeKey(route.Method, route.Path)] = operation
+}
+
+// Content of successful response. Response of route with its own content
+// type is not wrapped in common response
+func (d *Document) responseContent(route Route) map[string]MediaType {
+	switch route.ContentType {
+	case "":
+		return jsonContent(d.envelope(d.Schema(route.Response)))
+	case ContentTypeJSON:
+		return jsonContent(d.Schema(route.Response))
+	}
+	return map[string]MediaType{
+		route.ContentType: {Schema: &Schema{Type: TypeString}},
+	}
+}
+
+// Errors with the same status are described by one response. Body is
+// described by schema of errorBody or is null if it is nil
+func (d *Document) addErrors(operation *Operation, routeErrors []*errs.Error, errorBody *Schema) {
+	reasons := make(map[int][]string)
+	for _, err := range routeErrors {
+		if !contains(reasons[err.Status], err.Code) {
+			reasons[err.Status] = append(reasons[err.Status], err.Code)
+		}
+	}
+
+	for status, codes := range reasons {
+		sort.Strings(codes)
+		operation.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status) + ": " + strings.Join(codes, ", "),
+			Content:     jsonContent(d.errorEnvelope(errorBody)),
+		}
+	}
+}
+
+// Common response of application. Error is null in successful responses
+func (d *Document) envelope(body *Schema) *Schema {
+	return d.responseObject(nullable(d.Schema(utils.ErrorObject{})), body)
+}
+
+// Common response with error. Body is null if it is not described
+func (d *Document) errorEnvelope(body *Schema) *Schema {
+	if body == nil {
+		body = &Schema{Nullable: true}
+	}
+	return d.responseObject(d.Schema(utils.ErrorObject{}), body)
+}
+
+func (d *Document) responseObject(errorSchema *Schema, body *Schema) *Schema {
+	return &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"error": errorSchema,
+			"body":  body,
+		},
+		Required: []string{"error", "body"},
+	}
+}
+
+// Operation which describes route of router
+func (d *Document) Operation(method string, route string) (*Operation, bool) {
+	operation, ok := d.routes[routeKey(method, route)]
+	return operation, ok
+}
+
+// Id of operation in camel case, e.g. getCardsIdReveal
+func operationId(method string, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '-' || r == '_' || r == '.'
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+func routeKey(method string, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{
+		ContentTypeJSON: {Schema: schema},
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Moranilt/billing/validate"
+)
+
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+	TypeObject  = "object"
+
+	// Prefix of references to schemas of components
+	RefPrefix = "#/components/schemas/"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// Body which is one of several models, e.g. login responds with user or
+// with pending token when two-factor authentication is enabled
+type oneOf []any
+
+func OneOf(models ...any) any {
+	return oneOf(models)
+}
+
+// Patterns of rules of validate which can be described by schema
+var rulePatterns = map[string]string{
+	validate.RulePhone:  validate.PatternPhone,
+	validate.RuleDigits: `^[0-9]+$`,
+	validate.RuleLuhn:   `^[0-9]{12,19}$`,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema of model. Named structs are added to components and referenced
+// by name with package, e.g. cards.Card. Fields are named as in JSON.
+// Field is required if it has required rule of validate or if it is
+// always sent: it does not have omitempty, rules of validate and it is
+// not a pointer. Rules of validate become limits of schema
+func (d *Document) Schema(model any) *Schema {
+	if models, ok := model.(oneOf); ok {
+		schema := &Schema{}
+		for _, item := range models {
+			schema.OneOf = append(schema.OneOf, d.Schema(item))
+		}
+		return schema
+	}
+	if model == nil {
+		return &Schema{}
+	}
+	return d.typeSchema(reflect.TypeOf(model))
+}
+
+func (d *Document) typeSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		return nullable(d.typeSchema(t.Elem()))
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: TypeInteger, Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: TypeInteger, Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: TypeNumber, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: TypeNumber, Format: "double"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes bytes as base64 string
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: TypeString, Format: "byte"}
+		}
+		return &Schema{Type: TypeArray, Items: d.typeSchema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: TypeObject, AdditionalProperties: d.typeSchema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: TypeString, Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.ref(t)
+	}
+
+	// interfaces can hold any value
+	return &Schema{}
+}
+
+// Reference to schema of named struct. Schema is added to components
+// before fields are described, so recursive models are supported
+func (d *Document) ref(t reflect.Type) *Schema {
+	name := t.String()
+	if _, ok := d.Components.Schemas[name]; !ok {
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t)
+	}
+	return &Schema{Ref: RefPrefix + name}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       TypeObject,
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		// fields of embedded structs are written as fields of parent
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := d.structSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		rules := field.Tag.Get(validate.KeyTag)
+		fieldSchema := d.typeSchema(field.Type)
+		if rules != "" {
+			fieldSchema = applyRules(fieldSchema, field.Type, rules)
+		}
+		schema.Properties[name] = fieldSchema
+
+		if hasRule(rules, validate.RuleRequired) ||
+			(rules == "" && !hasOption(options, "omitempty") && field.Type.Kind() != reflect.Pointer) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// Limits of validate rules, e.g. max=100 becomes maxLength of string or
+// maximum of number
+func applyRules(schema *Schema, t reflect.Type, rules string) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// schema of reference cannot have limits
+	if schema.Ref != "" {
+		return schema
+	}
+	// rules of format are checked for every element of list
+	elements := schema
+	if schema.Type == TypeArray && schema.Items.Ref == "" {
+		elements = schema.Items
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case validate.RuleMin, validate.RuleMax, validate.RuleLen:
+			applyLimit(schema, t.Kind(), name, param)
+		case validate.RuleEmail:
+			elements.Format = "email"
+		case validate.RuleOneOf:
+			for _, value := range strings.Fields(param) {
+				elements.Enum = append(elements.Enum, value)
+			}
+		default:
+			if pattern, ok := rulePatterns[name]; ok {
+				elements.Pattern = pattern
+			}
+		}
+	}
+
+	return schema
+}
+
+func applyLimit(schema *Schema, kind reflect.Kind, rule string, param string) {
+	number, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	length := int(number)
+
+	switch kind {
+	case reflect.String:
+		if rule != validate.RuleMax {
+			schema.MinLength = &length
+		}
+		if rule != validate.RuleMin {
+			schema.MaxLength = &length
+		}
+	case reflect.Slice, reflect.Array:
+		if rule == validate.RuleMin {
+			schema.MinItems = &length
+		}
+		if rule == validate.RuleMax {
+			schema.MaxItems = &length
+		}
+	default:
+		if rule == validate.RuleMin {
+			schema.Minimum = &number
+		}
+		if rule == validate.RuleMax {
+			schema.Maximum = &number
+		}
+	}
+}
+
+// Siblings of $ref are ignored, so nullable reference is wrapped
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+	schema.Nullable = true
+	return schema
+}
+
+func hasRule(rules string, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		ruleName, _, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if ruleName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOption(options string, name string) bool {
+	for _, option := range strings.Split(options, ",") {
+		if option == name {
+			return true
+		}
+	}
+	return false
+}
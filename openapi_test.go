@@ -0,0 +1,1083 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/openapi"
+	"github.com/Moranilt/billing/services"
+	"github.com/Moranilt/billing/services/admin"
+	"github.com/Moranilt/billing/services/apikeys"
+	"github.com/Moranilt/billing/services/audit"
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/services/cards"
+	"github.com/Moranilt/billing/services/health"
+	"github.com/Moranilt/billing/services/lockout"
+	"github.com/Moranilt/billing/services/notifier"
+	"github.com/Moranilt/billing/services/oauth"
+	"github.com/Moranilt/billing/services/otp"
+	"github.com/Moranilt/billing/services/password"
+	"github.com/Moranilt/billing/services/twofactor"
+	"github.com/Moranilt/billing/services/user"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/rou"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	contractUserId        = 1
+	contractToken         = "Bearer valid"
+	contractMerchantToken = "Bearer merchant"
+	contractAdminToken    = "Bearer admin"
+	contractCardId        = "6f1c2a8e-3b5d-4c7e-9a0f-1d2e3f4a5b6c"
+	contractKeyId         = "0b7e4f2a-9c1d-4e3f-8a5b-6c7d8e9f0a1b"
+	contractPassword      = "password"
+	contractCode          = "123456"
+)
+
+// Roles of principals of valid tokens of contract tests
+var contractRoles = map[string]string{
+	contractToken:         auth.ROLE_USER,
+	contractMerchantToken: auth.ROLE_MERCHANT,
+	contractAdminToken:    auth.ROLE_ADMIN,
+}
+
+// Principal of valid token of contract tests
+type contractAccessDetails struct {
+	role string
+}
+
+func (contractAccessDetails) GetUserId() int         { return contractUserId }
+func (d contractAccessDetails) GetRole() string      { return d.role }
+func (contractAccessDetails) GetImpersonatorId() int { return 0 }
+func (contractAccessDetails) IsReadOnly() bool       { return false }
+func (contractAccessDetails) GetClientId() string    { return "" }
+func (contractAccessDetails) GetScopes() []string    { return nil }
+func (contractAccessDetails) GetAPIKeyId() string    { return "" }
+func (contractAccessDetails) GetAccessUuid() string  { return "access-uuid" }
+func (d contractAccessDetails) HasPermission(p string) bool {
+	return auth.HasPermission(d.role, p)
+}
+
+// Methods of services which are not used by routes are not implemented,
+// calls to them fail the test with panic
+type contractAuth struct {
+	auth.Authentication
+	steppedUp bool
+}
+
+func (a *contractAuth) GetTokenFromHeader(r *http.Request) (*jwt.Token, error) {
+	header := r.Header.Get(auth.KeyAuthorizationHeader)
+	if _, ok := contractRoles[header]; !ok {
+		return nil, auth.ErrNotValidToken
+	}
+	return &jwt.Token{Raw: header}, nil
+}
+
+func (a *contractAuth) GetTokenFromCookie(r *http.Request, key string) (*jwt.Token, error) {
+	return nil, auth.ErrEmptyToken
+}
+
+func (a *contractAuth) ExtractAccessMetaData(ctx context.Context, token *jwt.Token) (auth.AccessDetails, error) {
+	return contractAccessDetails{role: contractRoles[token.Raw]}, nil
+}
+
+func (a *contractAuth) IsSteppedUp(ctx context.Context, details auth.AccessDetails) (bool, error) {
+	return a.steppedUp, nil
+}
+
+func (a *contractAuth) CreateTokens(ctx context.Context, w http.ResponseWriter, userId int) error {
+	return nil
+}
+
+func (a *contractAuth) CreatePendingToken(ctx context.Context, userId int) (*auth.PendingTokenResponse, error) {
+	return &auth.PendingTokenResponse{TwoFactorRequired: true, PendingToken: "pending", ExpiresIn: 300}, nil
+}
+
+func (a *contractAuth) CheckPendingToken(ctx context.Context, value string) (int, error) {
+	if value != "pending" {
+		return 0, auth.ErrNotValidToken
+	}
+	return contractUserId, nil
+}
+
+func (a *contractAuth) RevokePendingToken(ctx context.Context, value string) error {
+	return nil
+}
+
+func (a *contractAuth) ParseToken(value string) (*jwt.Token, error) {
+	if value != "refresh" {
+		return nil, auth.ErrNotValidToken
+	}
+	return &jwt.Token{}, nil
+}
+
+func (a *contractAuth) RefreshTokenPair(ctx context.Context, refreshToken *jwt.Token) (*auth.TokenDetails, auth.AccessDetails, error) {
+	now := time.Now()
+	return &auth.TokenDetails{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		ATExpires:    now.Add(auth.TTLAccessToken),
+		RTExpires:    now.Add(auth.TTLRefreshToken),
+	}, contractAccessDetails{role: auth.ROLE_USER}, nil
+}
+
+func (a *contractAuth) StepUp(ctx context.Context, details auth.AccessDetails) error {
+	return nil
+}
+
+func (a *contractAuth) RevokeSessions(ctx context.Context, userId int) error {
+	return nil
+}
+
+func (a *contractAuth) CreateClientToken(ctx context.Context, clientId string, scopes []string) (*auth.ClientTokenResponse, error) {
+	return &auth.ClientTokenResponse{
+		AccessToken: "client",
+		TokenType:   auth.BearerScheme,
+		ExpiresIn:   3600,
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (a *contractAuth) RevokeAccessToken(ctx context.Context, userId int, accessUuid string) error {
+	return nil
+}
+
+func (a *contractAuth) CreateImpersonationToken(ctx context.Context, adminId int, userId int) (*auth.TokenDetails, error) {
+	return &auth.TokenDetails{AccessToken: "impersonation", ATUuid: "impersonation-uuid"}, nil
+}
+
+type contractUser struct {
+	user.UserMethods
+}
+
+func (u *contractUser) Get(ctx context.Context, userId int) (*user.User, error) {
+	return &user.User{
+		Id:        userId,
+		Email:     "user@example.com",
+		Firstname: "Ivan",
+		Lastname:  "Ivanov",
+		Phone:     "+79990000000",
+		Role:      auth.ROLE_USER,
+		CreatedAt: "2026-01-01T00:00:00Z",
+		Cards:     contractCards(),
+	}, nil
+}
+
+func (u *contractUser) GetAccount(ctx context.Context, login string) (*user.Account, error) {
+	return &user.Account{Id: contractUserId, Email: login}, nil
+}
+
+func (u *contractUser) CheckCredentials(ctx context.Context, login string, password string) (int, error) {
+	if password != contractPassword {
+		return 0, user.ErrNotValidCredentials
+	}
+	return contractUserId, nil
+}
+
+func (u *contractUser) GetIdByPhone(ctx context.Context, phone string) (int, error) {
+	return contractUserId, nil
+}
+
+func (u *contractUser) SetPassword(ctx context.Context, userId int, password string) error {
+	return nil
+}
+
+type contractCardsService struct {
+	cards.CardsMethods
+}
+
+func (c *contractCardsService) GetCards(ctx context.Context, userId int) ([]cards.Card, error) {
+	return contractCards(), nil
+}
+
+func (c *contractCardsService) Reveal(ctx context.Context, userId int, cardId string) (*cards.CardSecret, error) {
+	return &cards.CardSecret{Id: cardId, Number: "4242424242424242", CVC: 123}, nil
+}
+
+func contractCards() []cards.Card {
+	return []cards.Card{{
+		Id:      contractCardId,
+		Mask:    "4242 **** **** 4242",
+		Balance: 100.5,
+		State:   cards.CardState{Id: 1, Name: "active"},
+	}}
+}
+
+type contractTwoFactor struct {
+	twofactor.TwoFactorMethods
+	enabled bool
+}
+
+func (f *contractTwoFactor) IsEnabled(ctx context.Context, userId int) (bool, error) {
+	return f.enabled, nil
+}
+
+func (f *contractTwoFactor) Verify(ctx context.Context, userId int, code string) error {
+	if code != contractCode {
+		return twofactor.ErrNotValidCode
+	}
+	return nil
+}
+
+func (f *contractTwoFactor) Enroll(ctx context.Context, userId int) (*twofactor.Enrollment, error) {
+	return &twofactor.Enrollment{Secret: "SECRET", URI: "otpauth://totp/Billing:user?secret=SECRET"}, nil
+}
+
+func (f *contractTwoFactor) Confirm(ctx context.Context, userId int, code string) ([]string, error) {
+	if code != contractCode {
+		return nil, twofactor.ErrNotValidCode
+	}
+	return []string{"recovery-1", "recovery-2"}, nil
+}
+
+func (f *contractTwoFactor) Disable(ctx context.Context, userId int, code string) error {
+	return f.Verify(ctx, userId, code)
+}
+
+type contractLockout struct {
+	lockout.LockoutMethods
+	locked bool
+}
+
+func (l *contractLockout) Check(ctx context.Context, attempt lockout.Attempt) (time.Duration, error) {
+	if l.locked {
+		return time.Minute, lockout.ErrAccountLocked
+	}
+	return 0, nil
+}
+
+func (l *contractLockout) Fail(ctx context.Context, attempt lockout.Attempt) error {
+	return nil
+}
+
+func (l *contractLockout) Succeed(ctx context.Context, attempt lockout.Attempt) error {
+	return nil
+}
+
+func (l *contractLockout) IsLocked(ctx context.Context, userId int) (bool, error) {
+	return l.locked, nil
+}
+
+func (l *contractLockout) Unlock(ctx context.Context, userId int, reason string) error {
+	return nil
+}
+
+type contractOTP struct {
+	otp.OTPMethods
+}
+
+func (o *contractOTP) Send(ctx context.Context, purpose string, phone string) error {
+	return nil
+}
+
+func (o *contractOTP) Verify(ctx context.Context, purpose string, phone string, code string) error {
+	if code != contractCode {
+		return otp.ErrNotValidCode
+	}
+	return nil
+}
+
+type contractPasswordService struct {
+	password.PasswordMethods
+}
+
+func (p *contractPasswordService) CreateResetToken(ctx context.Context, userId int) (string, error) {
+	return "reset", nil
+}
+
+func (p *contractPasswordService) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	if token != "reset" {
+		return 0, password.ErrNotValidToken
+	}
+	return contractUserId, nil
+}
+
+type contractNotifier struct{}
+
+func (n *contractNotifier) Notify(ctx context.Context, recipient notifier.Recipient, subject string, message string) error {
+	return nil
+}
+
+type contractOAuth struct {
+	oauth.ClientsMethods
+}
+
+func (o *contractOAuth) Authenticate(ctx context.Context, clientId string, secret string) (*oauth.Client, error) {
+	if clientId != "client" || secret != "secret" {
+		return nil, oauth.ErrNotValidClient
+	}
+	return &oauth.Client{ClientId: clientId, Name: "shop", Scopes: []string{auth.PermissionCardsRead}}, nil
+}
+
+func (o *contractOAuth) GrantScopes(client *oauth.Client, scope string) ([]string, error) {
+	return client.Scopes, nil
+}
+
+func (o *contractOAuth) Create(ctx context.Context, client oauth.ClientCreate) (*oauth.ClientCredentials, error) {
+	return &oauth.ClientCredentials{
+		Client:       oauth.Client{ClientId: "client", Name: client.Name, Scopes: client.Scopes, CreatedAt: "2026-01-01T00:00:00Z"},
+		ClientSecret: "secret",
+	}, nil
+}
+
+type contractAudit struct {
+	audit.AuditMethods
+}
+
+func (a *contractAudit) Record(ctx context.Context, entry audit.Entry) error {
+	return nil
+}
+
+type contractAPIKeys struct {
+	apikeys.APIKeysMethods
+}
+
+func (k *contractAPIKeys) List(ctx context.Context, userId int) ([]apikeys.APIKey, error) {
+	return []apikeys.APIKey{contractAPIKey()}, nil
+}
+
+func (k *contractAPIKeys) Create(ctx context.Context, userId int, key apikeys.APIKeyCreate) (*apikeys.APIKeyCreated, error) {
+	return &apikeys.APIKeyCreated{APIKey: contractAPIKey(), Key: "key"}, nil
+}
+
+func (k *contractAPIKeys) Rotate(ctx context.Context, userId int, keyId string) (*apikeys.APIKeyCreated, error) {
+	if keyId != contractKeyId {
+		return nil, apikeys.ErrKeyNotFound
+	}
+	return &apikeys.APIKeyCreated{APIKey: contractAPIKey(), Key: "key"}, nil
+}
+
+func (k *contractAPIKeys) Revoke(ctx context.Context, userId int, keyId string) error {
+	if keyId != contractKeyId {
+		return apikeys.ErrKeyNotFound
+	}
+	return nil
+}
+
+func contractAPIKey() apikeys.APIKey {
+	return apikeys.APIKey{
+		Id:         contractKeyId,
+		Name:       "shop",
+		Prefix:     "bk_1234",
+		Scopes:     []string{auth.PermissionCardsRead},
+		AllowedIPs: []string{},
+		CreatedAt:  "2026-01-01T00:00:00Z",
+	}
+}
+
+type contractAdmin struct {
+	admin.AdminMethods
+	blocked bool
+}
+
+func (a *contractAdmin) SearchUsers(ctx context.Context, adminId int, query string) ([]admin.UserShort, error) {
+	if query == "" {
+		return nil, admin.ErrEmptySearchQuery
+	}
+	return []admin.UserShort{contractUserShort()}, nil
+}
+
+func (a *contractAdmin) GetUser(ctx context.Context, adminId int, userId int) (*admin.UserDetails, error) {
+	return &admin.UserDetails{
+		UserShort: contractUserShort(),
+		Passport:  &admin.Passport{Serial: 1234, Number: 567890, IssuedBy: "Mars", IssuedDate: "2026-01-01T00:00:00Z"},
+		Cards:     []admin.Card{contractAdminCard()},
+	}, nil
+}
+
+func (a *contractAdmin) Impersonate(ctx context.Context, adminId int, userId int) error {
+	return nil
+}
+
+func (a *contractAdmin) BlockCard(ctx context.Context, adminId int, cardId string, reason string) (*admin.Card, error) {
+	return &admin.Card{Id: cardId, Mask: "4242 **** **** 4242", State: cards.CardState{Id: 1, Name: "blocked"}}, nil
+}
+
+func (a *contractAdmin) UnblockCard(ctx context.Context, adminId int, cardId string, reason string) (*admin.Card, error) {
+	if !a.blocked {
+		return nil, admin.ErrCardIsNotBlocked
+	}
+	card := contractAdminCard()
+	return &card, nil
+}
+
+func (a *contractAdmin) AdjustBalance(ctx context.Context, adminId int, cardId string, adjustment admin.BalanceAdjustment) (*admin.Card, error) {
+	card := contractAdminCard()
+	card.Balance += adjustment.Amount
+	return &card, nil
+}
+
+func contractUserShort() admin.UserShort {
+	return admin.UserShort{
+		Id:        contractUserId,
+		Email:     "user@example.com",
+		Firstname: "Ivan",
+		Lastname:  "Ivanov",
+		Phone:     "+79990000000",
+		Role:      auth.ROLE_USER,
+		CreatedAt: "2026-01-01T00:00:00Z",
+	}
+}
+
+func contractAdminCard() admin.Card {
+	return admin.Card{
+		Id:        contractCardId,
+		Mask:      "4242 **** **** 4242",
+		Balance:   10050,
+		State:     cards.CardState{Id: 0, Name: "activated"},
+		CreatedAt: "2026-01-01T00:00:00Z",
+	}
+}
+
+type contractHealth struct {
+	health.HealthMethods
+	down bool
+}
+
+func (h *contractHealth) Live() health.Report {
+	return health.Report{Status: health.StatusUp}
+}
+
+func (h *contractHealth) Ready(ctx context.Context) health.Report {
+	if h.down {
+		return health.Report{
+			Status: health.StatusDown,
+			Checks: map[string]health.Check{"postgres": {Status: health.StatusDown, Error: errors.New("connection refused")}},
+		}
+	}
+	return health.Report{
+		Status: health.StatusUp,
+		Checks: map[string]health.Check{"postgres": {Status: health.StatusUp, LatencyMs: 1.5}},
+	}
+}
+
+type contractCase struct {
+	name string
+	// Route of router which is described in specification
+	method string
+	route  string
+	path   string
+	body   string
+	// Content type of body, JSON by default
+	contentType string
+	token       string
+	// Changes fakes before request
+	setup  func(repository *Repository, authorization *contractAuth)
+	status int
+}
+
+var contractCases = []contractCase{
+	{
+		name:   "metrics",
+		method: http.MethodGet,
+		route:  "/metrics",
+		path:   "/metrics",
+		status: http.StatusOK,
+	},
+	{
+		name:   "liveness",
+		method: http.MethodGet,
+		route:  "/healthz",
+		path:   "/healthz",
+		status: http.StatusOK,
+	},
+	{
+		name:   "readiness",
+		method: http.MethodGet,
+		route:  "/readyz",
+		path:   "/readyz",
+		status: http.StatusOK,
+	},
+	{
+		name:   "readiness when dependency is down",
+		method: http.MethodGet,
+		route:  "/readyz",
+		path:   "/readyz",
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.Health.(*contractHealth).down = true
+		},
+		status: health.ErrNotReady.Status,
+	},
+	{
+		name:   "specification",
+		method: http.MethodGet,
+		route:  "/openapi.json",
+		path:   "/openapi.json",
+		status: http.StatusOK,
+	},
+	{
+		name:   "login",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `{"login":"user@example.com","password":"password"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "login with two-factor authentication",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `{"login":"user@example.com","password":"password"}`,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.TwoFactor.(*contractTwoFactor).enabled = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "login with wrong password",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `{"login":"user@example.com","password":"wrong"}`,
+		status: user.ErrNotValidCredentials.Status,
+	},
+	{
+		name:   "login of locked account",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `{"login":"user@example.com","password":"password"}`,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.Lockout.(*contractLockout).locked = true
+		},
+		status: lockout.ErrAccountLocked.Status,
+	},
+	{
+		name:   "login without password",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `{"login":"user@example.com"}`,
+		status: errs.ErrValidation.Status,
+	},
+	{
+		name:   "login with body which is not JSON",
+		method: http.MethodPost,
+		route:  API_V1 + "/login",
+		path:   API_V1 + "/login",
+		body:   `login`,
+		status: errs.ErrBodyNotValid.Status,
+	},
+	{
+		name:   "second factor",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/2fa",
+		path:   API_V1 + "/login/2fa",
+		body:   `{"pending_token":"pending","code":"123456"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "second factor with wrong code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/2fa",
+		path:   API_V1 + "/login/2fa",
+		body:   `{"pending_token":"pending","code":"000000"}`,
+		status: twofactor.ErrNotValidCode.Status,
+	},
+	{
+		name:   "request login code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/otp",
+		path:   API_V1 + "/login/otp",
+		body:   `{"phone":"+79990000000"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "request login code for phone which is not valid",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/otp",
+		path:   API_V1 + "/login/otp",
+		body:   `{"phone":"phone"}`,
+		status: errs.ErrValidation.Status,
+	},
+	{
+		name:   "login by code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/otp/verify",
+		path:   API_V1 + "/login/otp/verify",
+		body:   `{"phone":"+79990000000","code":"123456"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "login by code with two-factor authentication",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/otp/verify",
+		path:   API_V1 + "/login/otp/verify",
+		body:   `{"phone":"+79990000000","code":"123456"}`,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.TwoFactor.(*contractTwoFactor).enabled = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "login by wrong code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/otp/verify",
+		path:   API_V1 + "/login/otp/verify",
+		body:   `{"phone":"+79990000000","code":"000000"}`,
+		status: otp.ErrNotValidCode.Status,
+	},
+	{
+		name:   "request unlock code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/unlock",
+		path:   API_V1 + "/login/unlock",
+		body:   `{"login":"user@example.com"}`,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.Lockout.(*contractLockout).locked = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "unlock account",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/unlock/verify",
+		path:   API_V1 + "/login/unlock/verify",
+		body:   `{"login":"user@example.com","code":"123456"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "unlock account with wrong code",
+		method: http.MethodPost,
+		route:  API_V1 + "/login/unlock/verify",
+		path:   API_V1 + "/login/unlock/verify",
+		body:   `{"login":"user@example.com","code":"000000"}`,
+		status: otp.ErrNotValidCode.Status,
+	},
+	{
+		name:   "forgot password",
+		method: http.MethodPost,
+		route:  API_V1 + "/password/forgot",
+		path:   API_V1 + "/password/forgot",
+		body:   `{"login":"user@example.com"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "reset password",
+		method: http.MethodPost,
+		route:  API_V1 + "/password/reset",
+		path:   API_V1 + "/password/reset",
+		body:   `{"token":"reset","password":"new password"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "reset password with token which is not valid",
+		method: http.MethodPost,
+		route:  API_V1 + "/password/reset",
+		path:   API_V1 + "/password/reset",
+		body:   `{"token":"wrong","password":"new password"}`,
+		status: password.ErrNotValidToken.Status,
+	},
+	{
+		name:   "refresh tokens",
+		method: http.MethodPost,
+		route:  API_V1 + "/token/refresh",
+		path:   API_V1 + "/token/refresh",
+		body:   `{"refresh_token":"refresh"}`,
+		status: http.StatusOK,
+	},
+	{
+		name:   "refresh tokens with invalid token",
+		method: http.MethodPost,
+		route:  API_V1 + "/token/refresh",
+		path:   API_V1 + "/token/refresh",
+		body:   `{"refresh_token":"invalid"}`,
+		status: auth.ErrNotValidToken.Status,
+	},
+	{
+		name:        "client token",
+		method:      http.MethodPost,
+		route:       API_V1 + "/oauth/token",
+		path:        API_V1 + "/oauth/token",
+		body:        "grant_type=client_credentials&client_id=client&client_secret=secret",
+		contentType: openapi.ContentTypeForm,
+		status:      http.StatusOK,
+	},
+	{
+		name:        "client token with wrong secret",
+		method:      http.MethodPost,
+		route:       API_V1 + "/oauth/token",
+		path:        API_V1 + "/oauth/token",
+		body:        "grant_type=client_credentials&client_id=client&client_secret=wrong",
+		contentType: openapi.ContentTypeForm,
+		status:      http.StatusUnauthorized,
+	},
+	{
+		name:        "client token with unsupported grant type",
+		method:      http.MethodPost,
+		route:       API_V1 + "/oauth/token",
+		path:        API_V1 + "/oauth/token",
+		body:        "grant_type=password",
+		contentType: openapi.ContentTypeForm,
+		status:      http.StatusBadRequest,
+	},
+	{
+		name:   "user",
+		method: http.MethodGet,
+		route:  API_V1 + "/user",
+		path:   API_V1 + "/user",
+		token:  contractToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "user without token",
+		method: http.MethodGet,
+		route:  API_V1 + "/user",
+		path:   API_V1 + "/user",
+		status: auth.ErrEmptyToken.Status,
+	},
+	{
+		name:   "cards",
+		method: http.MethodGet,
+		route:  API_V1 + "/cards",
+		path:   API_V1 + "/cards",
+		token:  contractToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "reveal card",
+		method: http.MethodGet,
+		route:  API_V1 + "/cards/:id/reveal",
+		path:   API_V1 + "/cards/" + contractCardId + "/reveal",
+		token:  contractToken,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			authorization.steppedUp = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "reveal card without step-up",
+		method: http.MethodGet,
+		route:  API_V1 + "/cards/:id/reveal",
+		path:   API_V1 + "/cards/" + contractCardId + "/reveal",
+		token:  contractToken,
+		status: auth.ErrStepUpRequired.Status,
+	},
+	{
+		name:   "reveal card with wrong id",
+		method: http.MethodGet,
+		route:  API_V1 + "/cards/:id/reveal",
+		path:   API_V1 + "/cards/1/reveal",
+		token:  contractToken,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			authorization.steppedUp = true
+		},
+		status: errs.ErrNotFound.Status,
+	},
+	{
+		name:   "API keys",
+		method: http.MethodGet,
+		route:  API_V1 + "/api-keys",
+		path:   API_V1 + "/api-keys",
+		token:  contractMerchantToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "API keys of user without permission",
+		method: http.MethodGet,
+		route:  API_V1 + "/api-keys",
+		path:   API_V1 + "/api-keys",
+		token:  contractToken,
+		status: auth.ErrPermissionDenied.Status,
+	},
+	{
+		name:   "create API key",
+		method: http.MethodPost,
+		route:  API_V1 + "/api-keys",
+		path:   API_V1 + "/api-keys",
+		body:   `{"name":"shop","scopes":["cards:read"]}`,
+		token:  contractMerchantToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "rotate API key",
+		method: http.MethodPost,
+		route:  API_V1 + "/api-keys/:id/rotate",
+		path:   API_V1 + "/api-keys/" + contractKeyId + "/rotate",
+		token:  contractMerchantToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "rotate API key which does not exist",
+		method: http.MethodPost,
+		route:  API_V1 + "/api-keys/:id/rotate",
+		path:   API_V1 + "/api-keys/" + contractCardId + "/rotate",
+		token:  contractMerchantToken,
+		status: apikeys.ErrKeyNotFound.Status,
+	},
+	{
+		name:   "revoke API key",
+		method: http.MethodDelete,
+		route:  API_V1 + "/api-keys/:id",
+		path:   API_V1 + "/api-keys/" + contractKeyId,
+		token:  contractMerchantToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "enroll second factor",
+		method: http.MethodPost,
+		route:  API_V1 + "/2fa/enroll",
+		path:   API_V1 + "/2fa/enroll",
+		token:  contractToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "confirm second factor",
+		method: http.MethodPost,
+		route:  API_V1 + "/2fa/confirm",
+		path:   API_V1 + "/2fa/confirm",
+		body:   `{"code":"123456"}`,
+		token:  contractToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "confirm second factor with wrong code",
+		method: http.MethodPost,
+		route:  API_V1 + "/2fa/confirm",
+		path:   API_V1 + "/2fa/confirm",
+		body:   `{"code":"000000"}`,
+		token:  contractToken,
+		status: twofactor.ErrNotValidCode.Status,
+	},
+	{
+		name:   "disable second factor",
+		method: http.MethodPost,
+		route:  API_V1 + "/2fa/disable",
+		path:   API_V1 + "/2fa/disable",
+		body:   `{"code":"123456"}`,
+		token:  contractToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "step-up",
+		method: http.MethodPost,
+		route:  API_V1 + "/2fa/step-up",
+		path:   API_V1 + "/2fa/step-up",
+		body:   `{"code":"123456"}`,
+		token:  contractToken,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.TwoFactor.(*contractTwoFactor).enabled = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "search users",
+		method: http.MethodGet,
+		route:  API_V1 + "/admin/users",
+		path:   API_V1 + "/admin/users?query=ivan",
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "search users without query",
+		method: http.MethodGet,
+		route:  API_V1 + "/admin/users",
+		path:   API_V1 + "/admin/users",
+		token:  contractAdminToken,
+		status: admin.ErrEmptySearchQuery.Status,
+	},
+	{
+		name:   "search users by user who is not admin",
+		method: http.MethodGet,
+		route:  API_V1 + "/admin/users",
+		path:   API_V1 + "/admin/users?query=ivan",
+		token:  contractToken,
+		status: auth.ErrPermissionDenied.Status,
+	},
+	{
+		name:   "user for admin",
+		method: http.MethodGet,
+		route:  API_V1 + "/admin/users/:id",
+		path:   API_V1 + "/admin/users/1",
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "impersonate user",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/users/:id/impersonate",
+		path:   API_V1 + "/admin/users/1/impersonate",
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "block card",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/cards/:id/block",
+		path:   API_V1 + "/admin/cards/" + contractCardId + "/block",
+		body:   `{"reason":"fraud"}`,
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "unblock card which is not blocked",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/cards/:id/unblock",
+		path:   API_V1 + "/admin/cards/" + contractCardId + "/unblock",
+		body:   `{"reason":"mistake"}`,
+		token:  contractAdminToken,
+		status: admin.ErrCardIsNotBlocked.Status,
+	},
+	{
+		name:   "unblock card",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/cards/:id/unblock",
+		path:   API_V1 + "/admin/cards/" + contractCardId + "/unblock",
+		body:   `{"reason":"mistake"}`,
+		token:  contractAdminToken,
+		setup: func(repository *Repository, authorization *contractAuth) {
+			repository.Admin.(*contractAdmin).blocked = true
+		},
+		status: http.StatusOK,
+	},
+	{
+		name:   "adjust balance",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/cards/:id/balance",
+		path:   API_V1 + "/admin/cards/" + contractCardId + "/balance",
+		body:   `{"amount":-50,"reason":"chargeback"}`,
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+	{
+		name:   "create OAuth client",
+		method: http.MethodPost,
+		route:  API_V1 + "/admin/oauth/clients",
+		path:   API_V1 + "/admin/oauth/clients",
+		body:   `{"name":"shop","scopes":["cards:read"]}`,
+		token:  contractAdminToken,
+		status: http.StatusOK,
+	},
+}
+
+// Every documented route responds as specification describes. Handlers
+// run with the same routes and middlewares as in main, services are fakes
+func TestResponsesMatchSpecification(t *testing.T) {
+	log, err := logger.NewLogger(contractLogConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	covered := make(map[string]bool)
+	for _, c := range contractCases {
+		t.Run(c.name, func(t *testing.T) {
+			authorization := &contractAuth{}
+			repository := contractRepository(authorization, log)
+			if c.setup != nil {
+				c.setup(repository, authorization)
+			}
+
+			router := rou.NewRouter()
+			contractRoutes(repository, router, authorization, log)
+
+			request := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			if c.body != "" {
+				contentType := c.contentType
+				if contentType == "" {
+					contentType = openapi.ContentTypeJSON
+				}
+				request.Header.Set("Content-Type", contentType)
+			}
+			if c.token != "" {
+				request.Header.Set(auth.KeyAuthorizationHeader, c.token)
+			}
+			recorder := httptest.NewRecorder()
+			utils.AccessLog(log, utils.RouterErrors(router)).ServeHTTP(recorder, request)
+
+			if recorder.Code != c.status {
+				t.Fatalf("expected status %d, got %d: %s", c.status, recorder.Code, recorder.Body.String())
+			}
+			err := repository.spec.CheckResponse(c.method, c.route, recorder.Code, recorder.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+		if c.status == http.StatusOK {
+			covered[c.method+" "+c.route] = true
+		}
+	}
+
+	// successful response of every described route is checked
+	pathParam := regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	for path, item := range apiSpec().Paths {
+		for method := range item {
+			route := strings.ToUpper(method) + " " + pathParam.ReplaceAllString(path, ":$1")
+			if !covered[route] {
+				t.Errorf("%s is described but its successful response is not checked", route)
+			}
+		}
+	}
+}
+
+// Route which is added to router without description in specification
+// fails this test, so specification cannot fall behind routes
+func TestRegisteredRoutesAreDescribed(t *testing.T) {
+	log, err := logger.NewLogger(contractLogConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	authorization := &contractAuth{}
+	repository := contractRepository(authorization, log)
+	routes := contractRoutes(repository, rou.NewRouter(), authorization, log)
+	if len(routes) == 0 {
+		t.Fatal("no routes were registered")
+	}
+	for _, route := range routes {
+		_, ok := repository.spec.Operation(route.Method, route.Pattern)
+		if !ok {
+			t.Errorf("%s %s is registered but not described", route.Method, route.Pattern)
+		}
+	}
+}
+
+func contractRepository(authorization *contractAuth, log logger.LoggerWriter) *Repository {
+	return &Repository{
+		Authorization: authorization,
+		User:          &contractUser{},
+		Cards:         &contractCardsService{},
+		Admin:         &contractAdmin{},
+		TwoFactor:     &contractTwoFactor{},
+		OTP:           &contractOTP{},
+		Lockout:       &contractLockout{},
+		Password:      &contractPasswordService{},
+		Notifier:      &contractNotifier{},
+		OAuth:         &contractOAuth{},
+		Audit:         &contractAudit{},
+		APIKeys:       &contractAPIKeys{},
+		Health:        &contractHealth{},
+		logger:        log,
+		spec:          apiSpec(),
+	}
+}
+
+// Register routes as main does and return them
+func contractRoutes(repository *Repository, router *rou.SimpleRouter, authorization *contractAuth, log logger.LoggerWriter) []utils.RegisteredRoute {
+	middleware := services.NewMiddlewareService(services.MiddlewareSettings{
+		Auth:   authorization,
+		Logger: log,
+	})
+	root := utils.NewRouteGroup(router, "")
+	repository.routesRoot(root)
+	repository.routesV1(root.Group(API_V1), middleware)
+	return root.Routes()
+}
+
+func contractLogConfig(t *testing.T) config.Log {
+	settings := config.Default().Log
+	settings.Dir = t.TempDir()
+	return settings
+}
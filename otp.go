@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Moranilt/billing/services/otp"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) LoginOTPRequest(ctx *rou.Context) {
+	var body otp.SendRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	_, err = r.User.GetIdByPhone(ctx.Request().Context(), body.Phone)
+	// response is the same for unknown phone, so it cannot be used
+	// to find out registered phone numbers
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.SuccessJSONResponse(nil)
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.OTP.Send(ctx.Request().Context(), otp.PurposeLogin, body.Phone)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
+
+func (r *Repository) LoginOTPVerify(ctx *rou.Context) {
+	var body otp.VerifyRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.OTP.Verify(ctx.Request().Context(), otp.PurposeLogin, body.Phone, body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := r.User.GetIdByPhone(ctx.Request().Context(), body.Phone)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	r.authenticated(ctx, userId)
+}
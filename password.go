@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/services/lockout"
+	"github.com/Moranilt/billing/services/notifier"
+	"github.com/Moranilt/billing/services/password"
+	"github.com/Moranilt/billing/services/user"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) PasswordForgot(ctx *rou.Context) {
+	var body password.ForgotRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	account, err := r.User.GetAccount(ctx.Request().Context(), body.Login)
+	// response is the same for unknown login, so it cannot be used
+	// to find out registered users
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.SuccessJSONResponse(nil)
+		return
+	}
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	token, err := r.Password.CreateResetToken(ctx.Request().Context(), account.Id)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Notifier.Notify(
+		ctx.Request().Context(),
+		notifier.Recipient{Email: account.Email, Phone: account.Phone},
+		"Password reset",
+		fmt.Sprintf("Use this token to reset your password: %s. It expires in %s.", token, password.TTLResetToken),
+	)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
+
+func (r *Repository) PasswordReset(ctx *rou.Context) {
+	var body password.ResetRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	// token is checked after password, so it is not spent on invalid password
+	err = user.ValidatePassword(body.Password)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := r.Password.ConsumeResetToken(ctx.Request().Context(), body.Token)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.User.SetPassword(ctx.Request().Context(), userId, body.Password)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Authorization.RevokeSessions(ctx.Request().Context(), userId)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Lockout.Unlock(ctx.Request().Context(), userId, lockout.UnlockReasonPasswordReset)
+	if err != nil {
+		r.log(ctx).Error("request failed", logger.Err(err))
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Moranilt/billing/services"
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/utils"
+)
+
+// Prefix of routes of the first version of API. Next versions are mounted
+// next to it with their own prefix, e.g. /v2
+const API_V1 = "/v1"
+
+// Routes without version were replaced by routes of API_V1. They are
+// served until sunset, so existing clients have time to move
+var unversionedDeprecation = utils.Deprecation{
+	At:        time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+	Sunset:    time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	Successor: API_V1,
+}
+
+// Routes of service itself which are not versioned: metrics, probes of
+// orchestrator and specification of API
+func (r *Repository) routesRoot(routes *utils.RouteGroup) {
+	routes.Get("/metrics", r.Metrics)
+	routes.Get("/healthz", r.Healthz)
+	routes.Get("/readyz", r.Readyz)
+	routes.Get("/openapi.json", r.OpenAPI)
+}
+
+// Routes of the first version of API. Next version gets its own function
+// with its own group, handlers which did not change can be reused
+func (r *Repository) routesV1(api *utils.RouteGroup, middleware *services.Middleware) {
+	api.Post("/login", r.Login)
+	api.Post("/login/2fa", r.LoginTwoFactor)
+	api.Post("/login/otp", r.LoginOTPRequest)
+	api.Post("/login/otp/verify", r.LoginOTPVerify)
+	api.Post("/login/unlock", r.LoginUnlockRequest)
+	api.Post("/login/unlock/verify", r.LoginUnlockVerify)
+	api.Post("/password/forgot", r.PasswordForgot)
+	api.Post("/password/reset", r.PasswordReset)
+	api.Post("/token/refresh", r.TokenRefresh)
+	api.Post("/oauth/token", r.OAuthToken)
+	api.Get("/user", r.UserInfo).Middleware(
+		middleware.AuthorizedUser,
+		middleware.RequirePermission(auth.PermissionUserRead),
+	)
+	api.Get("/cards", r.CardsList).Middleware(
+		middleware.AuthorizedUser,
+		middleware.RequirePermission(auth.PermissionCardsRead),
+	)
+	api.Get("/cards/:id/reveal", r.CardReveal).Middleware(
+		middleware.AuthorizedUser,
+		middleware.RequirePermission(auth.PermissionCardsRead),
+		middleware.RequireStepUp,
+	)
+
+	apiKeysRoutes := api.Group("/api-keys",
+		middleware.AuthorizedUser,
+		middleware.RequireSession,
+		middleware.RequirePermission(auth.PermissionAPIKeysManage),
+	)
+	apiKeysRoutes.Get("", r.APIKeysList)
+	apiKeysRoutes.Post("", r.APIKeysCreate)
+	apiKeysRoutes.Post("/:id/rotate", r.APIKeysRotate)
+	apiKeysRoutes.Delete("/:id", r.APIKeysRevoke)
+
+	twoFactorRoutes := api.Group("/2fa", middleware.AuthorizedUser, middleware.RequireSession)
+	twoFactorRoutes.Post("/enroll", r.TwoFactorEnroll)
+	twoFactorRoutes.Post("/confirm", r.TwoFactorConfirm)
+	twoFactorRoutes.Post("/disable", r.TwoFactorDisable)
+	twoFactorRoutes.Post("/step-up", r.TwoFactorStepUp)
+
+	adminRoutes := api.Group("/admin",
+		middleware.AuthorizedUser,
+		middleware.RequireSession,
+		middleware.RequireRole(auth.ROLE_ADMIN),
+	)
+	adminRoutes.Get("/users", r.AdminSearchUsers)
+	adminRoutes.Get("/users/:id", r.AdminUser)
+	adminRoutes.Post("/users/:id/impersonate", r.AdminImpersonate)
+	adminRoutes.Post("/cards/:id/block", r.AdminBlockCard)
+	adminRoutes.Post("/cards/:id/unblock", r.AdminUnblockCard)
+	adminRoutes.Post("/cards/:id/balance", r.AdminAdjustBalance)
+	adminRoutes.Post("/oauth/clients", r.AdminCreateOAuthClient)
+}
@@ -0,0 +1,272 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Moranilt/billing/services/audit"
+	"github.com/Moranilt/billing/services/cards"
+	"github.com/jmoiron/sqlx"
+)
+
+type Admin struct {
+	db    *sqlx.DB
+	audit audit.AuditMethods
+}
+
+type AdminMethods interface {
+	// Search users by part of email, phone or name
+	SearchUsers(ctx context.Context, adminId int, query string) ([]UserShort, error)
+	// Get user with passport and all of his cards
+	GetUser(ctx context.Context, adminId int, userId int) (*UserDetails, error)
+	// Set state of card to blocked
+	BlockCard(ctx context.Context, adminId int, cardId string, reason string) (*Card, error)
+	// Set state of blocked card to activated
+	UnblockCard(ctx context.Context, adminId int, cardId string, reason string) (*Card, error)
+	// Add amount in minor units to balance of card. Amount might be negative
+	AdjustBalance(ctx context.Context, adminId int, cardId string, adjustment BalanceAdjustment) (*Card, error)
+	// Store impersonation of user to audit trail
+	Impersonate(ctx context.Context, adminId int, userId int) error
+}
+
+func NewService(db *sqlx.DB, audit audit.AuditMethods) AdminMethods {
+	return &Admin{db: db, audit: audit}
+}
+
+const cardQuery = `SELECT
+	cards.id,
+	cards.mask,
+	round(cards.balance*100)::bigint as balance,
+	cards.state_id,
+	cs.description as state_name,
+	cards.created_at
+	FROM cards
+	INNER JOIN card_states as cs ON cs.id=cards.state_id`
+
+// Wildcards of query are searched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (a *Admin) SearchUsers(ctx context.Context, adminId int, query string) ([]UserShort, error) {
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+
+	users := []UserShort{}
+	err := a.db.SelectContext(ctx, &users, `SELECT
+	id, email, firstname, lastname, patronymic, phone, role, created_at
+	FROM users
+	WHERE email ILIKE $1 ESCAPE '\'
+	OR phone ILIKE $1 ESCAPE '\'
+	OR concat_ws(' ', lastname, firstname, patronymic) ILIKE $1 ESCAPE '\'
+	OR concat_ws(' ', firstname, lastname) ILIKE $1 ESCAPE '\'
+	ORDER BY id
+	LIMIT $2`, "%"+likeEscaper.Replace(query)+"%", SEARCH_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.audit.Record(ctx, audit.Entry{
+		ActorId:    adminId,
+		Action:     audit.ActionUsersSearch,
+		TargetType: audit.TargetUser,
+		TargetId:   "*",
+		Details:    map[string]any{"query": query, "found": len(users)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (a *Admin) GetUser(ctx context.Context, adminId int, userId int) (*UserDetails, error) {
+	var user UserDetails
+	err := a.db.GetContext(ctx, &user.UserShort, `SELECT
+	id, email, firstname, lastname, patronymic, phone, role, created_at
+	FROM users
+	WHERE id=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var passport Passport
+	err = a.db.GetContext(ctx, &passport, `SELECT serial, number, issued_by, issued_date
+	FROM passports
+	WHERE user_id=$1
+	ORDER BY created_at DESC
+	LIMIT 1`, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		user.Passport = &passport
+	}
+
+	user.Cards = []Card{}
+	err = a.db.SelectContext(ctx, &user.Cards, cardQuery+` WHERE cards.user_id=$1 ORDER BY cards.created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range user.Cards {
+		fillState(&user.Cards[i])
+	}
+
+	err = a.audit.Record(ctx, audit.Entry{
+		ActorId:    adminId,
+		Action:     audit.ActionUsersView,
+		TargetType: audit.TargetUser,
+		TargetId:   fmt.Sprint(userId),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (a *Admin) BlockCard(ctx context.Context, adminId int, cardId string, reason string) (*Card, error) {
+	return a.setCardState(ctx, adminId, cardId, reason, cards.CARD_STATE_BLOCKED, audit.ActionCardsBlock)
+}
+
+func (a *Admin) UnblockCard(ctx context.Context, adminId int, cardId string, reason string) (*Card, error) {
+	return a.setCardState(ctx, adminId, cardId, reason, cards.CARD_STATE_ACTIVATED, audit.ActionCardsUnblock)
+}
+
+func (a *Admin) setCardState(ctx context.Context, adminId int, cardId string, reason string, stateId int, action string) (*Card, error) {
+	if reason == "" {
+		return nil, ErrEmptyReason
+	}
+
+	tx, err := a.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var card Card
+	err = tx.GetContext(ctx, &card, cardQuery+` WHERE cards.id=$1 FOR UPDATE OF cards`, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.StateId == cards.CARD_STATE_OUTDATED {
+		return nil, ErrCardIsOutdated
+	}
+	if card.StateId == stateId {
+		return nil, ErrCardStateNotChanged
+	}
+	if stateId == cards.CARD_STATE_ACTIVATED && card.StateId != cards.CARD_STATE_BLOCKED {
+		return nil, ErrCardIsNotBlocked
+	}
+
+	previousState := card.StateId
+	_, err = tx.ExecContext(ctx, `UPDATE cards SET state_id=$1 WHERE id=$2`, stateId, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.audit.RecordTx(ctx, tx, audit.Entry{
+		ActorId:    adminId,
+		Action:     action,
+		TargetType: audit.TargetCard,
+		TargetId:   cardId,
+		Reason:     reason,
+		Details:    map[string]any{"previous_state": previousState, "state": stateId},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &card, cardQuery+` WHERE cards.id=$1`, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	fillState(&card)
+	return &card, nil
+}
+
+func (a *Admin) AdjustBalance(ctx context.Context, adminId int, cardId string, adjustment BalanceAdjustment) (*Card, error) {
+	if adjustment.Reason == "" {
+		return nil, ErrEmptyReason
+	}
+	if adjustment.Amount == 0 {
+		return nil, ErrZeroAmount
+	}
+
+	tx, err := a.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var card Card
+	err = tx.GetContext(ctx, &card, cardQuery+` WHERE cards.id=$1 FOR UPDATE OF cards`, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	previousBalance := card.Balance
+	if previousBalance+adjustment.Amount < 0 {
+		return nil, ErrNegativeBalance
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE cards SET balance=balance+$1::numeric/100 WHERE id=$2`, adjustment.Amount, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &card, cardQuery+` WHERE cards.id=$1`, cardId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.audit.RecordTx(ctx, tx, audit.Entry{
+		ActorId:    adminId,
+		Action:     audit.ActionCardsBalanceAdjust,
+		TargetType: audit.TargetCard,
+		TargetId:   cardId,
+		Reason:     adjustment.Reason,
+		Details: map[string]any{
+			"amount":           adjustment.Amount,
+			"previous_balance": previousBalance,
+			"balance":          card.Balance,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	fillState(&card)
+	return &card, nil
+}
+
+func (a *Admin) Impersonate(ctx context.Context, adminId int, userId int) error {
+	return a.audit.Record(ctx, audit.Entry{
+		ActorId:    adminId,
+		Action:     audit.ActionUsersImpersonate,
+		TargetType: audit.TargetUser,
+		TargetId:   fmt.Sprint(userId),
+		Details:    map[string]any{"read_only": true},
+	})
+}
+
+func fillState(card *Card) {
+	card.State = cards.CardState{
+		Id:   card.StateId,
+		Name: card.StateName,
+	}
+}
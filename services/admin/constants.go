@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	SEARCH_LIMIT = 50
+)
+
+var (
+	ErrEmptySearchQuery    = errs.Unprocessable("search_query_missing", "search query was not provided")
+	ErrEmptyReason         = errs.Unprocessable("reason_missing", "reason was not provided")
+	ErrZeroAmount          = errs.Unprocessable("amount_is_zero", "amount should not be zero")
+	ErrNegativeBalance     = errs.Conflict("balance_negative", "balance cannot become negative")
+	ErrCardIsOutdated      = errs.Conflict("card_is_outdated", "card is outdated")
+	ErrCardStateNotChanged = errs.Conflict("card_state_not_changed", "card already has this state")
+	ErrCardIsNotBlocked    = errs.Conflict("card_is_not_blocked", "card is not blocked")
+)
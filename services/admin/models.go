@@ -0,0 +1,48 @@
+package admin
+
+import "github.com/Moranilt/billing/services/cards"
+
+type UserShort struct {
+	Id         int    `json:"id" db:"id"`
+	Email      string `json:"email" db:"email"`
+	Firstname  string `json:"firstname" db:"firstname"`
+	Lastname   string `json:"lastname" db:"lastname"`
+	Patronymic string `json:"patronymic" db:"patronymic"`
+	Phone      string `json:"phone" db:"phone"`
+	Role       string `json:"role" db:"role"`
+	CreatedAt  string `json:"created_at" db:"created_at"`
+}
+
+type UserDetails struct {
+	UserShort
+	Passport *Passport `json:"passport"`
+	Cards    []Card    `json:"cards"`
+}
+
+type Passport struct {
+	Serial     int    `json:"serial" db:"serial"`
+	Number     int    `json:"number" db:"number"`
+	IssuedBy   string `json:"issued_by" db:"issued_by"`
+	IssuedDate string `json:"issued_date" db:"issued_date"`
+}
+
+type Card struct {
+	Id   string `json:"id" db:"id"`
+	Mask string `json:"mask" db:"mask"`
+	// Balance in minor units of currency, e.g. cents
+	Balance   int64           `json:"balance" db:"balance"`
+	State     cards.CardState `json:"state" db:"-"`
+	StateId   int             `json:"-" db:"state_id"`
+	StateName string          `json:"-" db:"state_name"`
+	CreatedAt string          `json:"created_at" db:"created_at"`
+}
+
+type BalanceAdjustment struct {
+	// Amount in minor units of currency, negative amount debits card
+	Amount int64  `json:"amount" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type CardStateChange struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
@@ -0,0 +1,251 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type APIKeys struct {
+	db *sqlx.DB
+}
+
+type APIKeysMethods interface {
+	// Create key of user. Scopes of key should be granted to role of user
+	Create(ctx context.Context, userId int, key APIKeyCreate) (*APIKeyCreated, error)
+	// List of not revoked keys of user
+	List(ctx context.Context, userId int) ([]APIKey, error)
+	// Replace secret of key. Previous secret stops working immediately
+	Rotate(ctx context.Context, userId int, keyId string) (*APIKeyCreated, error)
+	Revoke(ctx context.Context, userId int, keyId string) error
+	// Implements auth.APIKeyVerifier
+	VerifyAPIKey(ctx context.Context, key string, ip string) (*auth.APIKeyOwner, error)
+}
+
+func NewService(db *sqlx.DB) APIKeysMethods {
+	return &APIKeys{db: db}
+}
+
+const selectKey = `SELECT id, name, prefix, scopes, allowed_ips, created_at, rotated_at, last_used_at
+	FROM api_keys`
+
+func (k *APIKeys) Create(ctx context.Context, userId int, key APIKeyCreate) (*APIKeyCreated, error) {
+	if key.Name == "" {
+		return nil, ErrEmptyName
+	}
+	if len(key.Scopes) == 0 {
+		return nil, ErrEmptyScopes
+	}
+
+	var role string
+	err := k.db.GetContext(ctx, &role, `SELECT role FROM users WHERE id=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range key.Scopes {
+		if !auth.HasPermission(role, scope) {
+			return nil, ErrScopeNotAllowed
+		}
+	}
+
+	allowedIPs := pq.StringArray{}
+	for _, ip := range key.AllowedIPs {
+		if !isValidIP(ip) {
+			return nil, ErrNotValidIP
+		}
+		allowedIPs = append(allowedIPs, ip)
+	}
+
+	prefix, secret, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var created APIKeyCreated
+	err = k.db.QueryRowxContext(ctx, `INSERT INTO api_keys
+	(user_id, name, prefix, key_hash, scopes, allowed_ips)
+	VALUES($1, $2, $3, $4, $5, $6)
+	RETURNING id, name, prefix, scopes, allowed_ips, created_at, rotated_at, last_used_at`,
+		userId, key.Name, prefix, hashKey(secret), pq.StringArray(key.Scopes), allowedIPs,
+	).StructScan(&created.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	created.Key = formatKey(prefix, secret)
+	return &created, nil
+}
+
+func (k *APIKeys) List(ctx context.Context, userId int) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := k.db.SelectContext(ctx, &keys, selectKey+` WHERE user_id=$1 AND revoked_at IS NULL ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (k *APIKeys) Rotate(ctx context.Context, userId int, keyId string) (*APIKeyCreated, error) {
+	prefix, secret, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated APIKeyCreated
+	err = k.db.QueryRowxContext(ctx, `UPDATE api_keys
+	SET prefix=$3, key_hash=$4, rotated_at=CURRENT_TIMESTAMP
+	WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL
+	RETURNING id, name, prefix, scopes, allowed_ips, created_at, rotated_at, last_used_at`,
+		keyId, userId, prefix, hashKey(secret),
+	).StructScan(&rotated.APIKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rotated.Key = formatKey(prefix, secret)
+	return &rotated, nil
+}
+
+func (k *APIKeys) Revoke(ctx context.Context, userId int, keyId string) error {
+	result, err := k.db.ExecContext(ctx, `UPDATE api_keys
+	SET revoked_at=CURRENT_TIMESTAMP
+	WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL`, keyId, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+func (k *APIKeys) VerifyAPIKey(ctx context.Context, key string, ip string) (*auth.APIKeyOwner, error) {
+	prefix, secret, ok := parseKey(key)
+	if !ok {
+		return nil, ErrNotValidKey
+	}
+
+	var stored storedKey
+	err := k.db.GetContext(ctx, &stored, `SELECT api_keys.id, api_keys.user_id, users.role, api_keys.key_hash, api_keys.scopes, api_keys.allowed_ips
+	FROM api_keys
+	INNER JOIN users ON users.id=api_keys.user_id
+	WHERE api_keys.prefix=$1 AND api_keys.revoked_at IS NULL`, prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotValidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored.KeyHash), []byte(hashKey(secret))) != 1 {
+		return nil, ErrNotValidKey
+	}
+
+	if !isAllowedIP(stored.AllowedIPs, ip) {
+		return nil, ErrIPNotAllowed
+	}
+
+	_, err = k.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at=CURRENT_TIMESTAMP WHERE id=$1`, stored.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.APIKeyOwner{
+		KeyId:  stored.Id,
+		UserId: stored.UserId,
+		Role:   stored.Role,
+		Scopes: stored.Scopes,
+	}, nil
+}
+
+// Key looks like "bk_<prefix>_<secret>". Prefix is stored as is and can be
+// shown to user to recognize the key
+func generateKey() (prefix string, secret string, err error) {
+	prefixBytes := make([]byte, PREFIX_SIZE)
+	_, err = rand.Read(prefixBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	secretBytes := make([]byte, SECRET_SIZE)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(prefixBytes), base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+func formatKey(prefix string, secret string) string {
+	return KEY_PREFIX + "_" + prefix + "_" + secret
+}
+
+func parseKey(key string) (prefix string, secret string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != KEY_PREFIX || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+
+	return parts[1], parts[2], true
+}
+
+// Secret of key is long random string, so fast hash is enough
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidIP(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+// Key without allowed IPs can be used from any IP
+func isAllowedIP(allowedIPs []string, value string) bool {
+	if len(allowedIPs) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+
+	for _, allowed := range allowedIPs {
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+
+		_, network, err := net.ParseCIDR(allowed)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,21 @@
+package apikeys
+
+import (
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	KEY_PREFIX  = "bk"
+	PREFIX_SIZE = 4
+	SECRET_SIZE = 32
+)
+
+var (
+	ErrEmptyName       = errs.Unprocessable("name_missing", "name of key was not provided")
+	ErrEmptyScopes     = errs.Unprocessable("scopes_missing", "scopes of key were not provided")
+	ErrScopeNotAllowed = errs.Forbidden("scope_not_allowed", "scope is not allowed for owner of key")
+	ErrNotValidIP      = errs.Unprocessable("ip_not_valid", "allowed IP is not valid")
+	ErrNotValidKey     = errs.Unauthorized("api_key_not_valid", "API key is not valid")
+	ErrIPNotAllowed    = errs.Forbidden("ip_not_allowed", "API key is not allowed for this IP")
+	ErrKeyNotFound     = errs.NotFound("api_key_not_found", "API key was not found")
+)
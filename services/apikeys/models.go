@@ -0,0 +1,36 @@
+package apikeys
+
+import "github.com/lib/pq"
+
+type APIKey struct {
+	Id         string         `json:"id" db:"id"`
+	Name       string         `json:"name" db:"name"`
+	Prefix     string         `json:"prefix" db:"prefix"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	AllowedIPs pq.StringArray `json:"allowed_ips" db:"allowed_ips"`
+	CreatedAt  string         `json:"created_at" db:"created_at"`
+	RotatedAt  *string        `json:"rotated_at" db:"rotated_at"`
+	LastUsedAt *string        `json:"last_used_at" db:"last_used_at"`
+}
+
+// Key is returned only once when it is created or rotated,
+// only hash of key is stored
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyCreate struct {
+	Name       string   `json:"name" validate:"required,max=100"`
+	Scopes     []string `json:"scopes" validate:"required,max=20"`
+	AllowedIPs []string `json:"allowed_ips" validate:"max=50,ip"`
+}
+
+type storedKey struct {
+	Id         string         `db:"id"`
+	UserId     int            `db:"user_id"`
+	Role       string         `db:"role"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	AllowedIPs pq.StringArray `db:"allowed_ips"`
+}
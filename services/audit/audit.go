@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Audit struct {
+	db *sqlx.DB
+}
+
+type AuditMethods interface {
+	// Write entry to audit trail
+	Record(ctx context.Context, entry Entry) error
+	// Write entry to audit trail inside of transaction, so entry is
+	// stored only if the audited action is committed
+	RecordTx(ctx context.Context, tx *sqlx.Tx, entry Entry) error
+}
+
+func NewService(db *sqlx.DB) AuditMethods {
+	return &Audit{db: db}
+}
+
+const insertQuery = `INSERT INTO audit_log
+	(actor_id, action, target_type, target_id, reason, details)
+	VALUES($1, $2, $3, $4, NULLIF($5, ''), $6)`
+
+func (a *Audit) Record(ctx context.Context, entry Entry) error {
+	details, err := marshalDetails(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.ExecContext(ctx, insertQuery, entry.ActorId, entry.Action, entry.TargetType, entry.TargetId, entry.Reason, details)
+	return err
+}
+
+func (a *Audit) RecordTx(ctx context.Context, tx *sqlx.Tx, entry Entry) error {
+	details, err := marshalDetails(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, insertQuery, entry.ActorId, entry.Action, entry.TargetType, entry.TargetId, entry.Reason, details)
+	return err
+}
+
+func marshalDetails(details any) (*string, error) {
+	if details == nil {
+		return nil, nil
+	}
+
+	bytes, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	result := string(bytes)
+	return &result, nil
+}
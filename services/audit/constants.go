@@ -0,0 +1,15 @@
+package audit
+
+const (
+	TargetUser        = "user"
+	TargetCard        = "card"
+	TargetOAuthClient = "oauth_client"
+
+	ActionUsersSearch        = "users.search"
+	ActionUsersView          = "users.view"
+	ActionUsersImpersonate   = "users.impersonate"
+	ActionCardsBlock         = "cards.block"
+	ActionCardsUnblock       = "cards.unblock"
+	ActionCardsBalanceAdjust = "cards.balance_adjust"
+	ActionOAuthClientsCreate = "oauth_clients.create"
+)
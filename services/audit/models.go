@@ -0,0 +1,10 @@
+package audit
+
+type Entry struct {
+	ActorId    int    `db:"actor_id"`
+	Action     string `db:"action"`
+	TargetType string `db:"target_type"`
+	TargetId   string `db:"target_id"`
+	Reason     string `db:"reason"`
+	Details    any    `db:"details"`
+}
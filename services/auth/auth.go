@@ -2,12 +2,18 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/metrics"
+	"github.com/Moranilt/billing/utils"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
@@ -15,51 +21,119 @@ import (
 )
 
 type authService struct {
-	db           *sqlx.DB
-	redisClient  *redis.Client
-	redisContext context.Context
-	secret       string
+	db          *sqlx.DB
+	redisClient *redis.Client
+	secret      string
+	apiKeys     APIKeyVerifier
 }
 
 type Authentication interface {
-	// Create access- and refresh-token and store it to Set-Cookie header
-	// using http.ResponseWriter. Stores keys of tokens to Redis
-	CreateTokens(w http.ResponseWriter, userId int, role string) error
+	// Create access- and refresh-token for the role stored in DB for user
+	// and store it to Set-Cookie header using http.ResponseWriter.
+	// Stores keys of tokens to Redis
+	CreateTokens(ctx context.Context, w http.ResponseWriter, userId int) error
+	// Create access- and refresh-token and store keys of tokens to Redis.
+	// Tokens are returned to the caller instead of being set to cookies
+	IssueTokens(ctx context.Context, userId int) (*TokenDetails, error)
+	// Create read-only access token of user on behalf of admin.
+	// Refresh token is not created, so impersonation ends with TTL of token
+	CreateImpersonationToken(ctx context.Context, adminId int, userId int) (*TokenDetails, error)
 	// Get token from Cookie request by token name
 	GetTokenFromCookie(r *http.Request, key string) (*jwt.Token, error)
+	// Get token from "Authorization: Bearer <token>" header
+	GetTokenFromHeader(r *http.Request) (*jwt.Token, error)
+	// Get access token from Authorization header if it was provided,
+	// otherwise from Cookie
+	GetAccessToken(r *http.Request) (*jwt.Token, error)
+	// Get details of API key if X-API-Key header was provided,
+	// otherwise details of access token
+	GetRequestAccessDetails(r *http.Request) (AccessDetails, error)
+	// Verify signature of raw token string
+	ParseToken(value string) (*jwt.Token, error)
 	// Extract fields from access token
-	ExtractAccessMetaData(token *jwt.Token) (AccessDetails, error)
+	ExtractAccessMetaData(ctx context.Context, token *jwt.Token) (AccessDetails, error)
 	// Extract fields from refresh token
-	ExtractRefreshMetaData(token *jwt.Token) (AccessDetails, error)
+	ExtractRefreshMetaData(ctx context.Context, token *jwt.Token) (AccessDetails, error)
 	// Check for existing refresh-token, delete refresh-token from Redis
-	// and create new tokens using CreateTokens method
-	RefreshToken(w http.ResponseWriter, refreshToken *jwt.Token) (AccessDetails, error)
+	// and create new tokens using CreateTokens method. Returns principal
+	// of new tokens. Refresh-token which was used less than TTLRefreshGrace
+	// ago gives the same new tokens
+	RefreshToken(ctx context.Context, w http.ResponseWriter, refreshToken *jwt.Token) (AccessDetails, error)
+	// Same as RefreshToken but returns new tokens instead of
+	// storing them to Set-Cookie header
+	RefreshTokenPair(ctx context.Context, refreshToken *jwt.Token) (*TokenDetails, AccessDetails, error)
+	// Create short-lived token which proves that user passed the first
+	// factor and can be exchanged to tokens only with valid second factor
+	CreatePendingToken(ctx context.Context, userId int) (*PendingTokenResponse, error)
+	// Get user id from pending token. Every call is counted as attempt
+	// and token is revoked after MaxPendingAttempts
+	CheckPendingToken(ctx context.Context, value string) (int, error)
+	// Revoke pending token after successful verification of second factor
+	RevokePendingToken(ctx context.Context, value string) error
+	// Mark session of access token as verified by second factor
+	StepUp(ctx context.Context, details AccessDetails) error
+	// Check that session of access token was verified by second factor
+	// not earlier than TTLStepUp ago
+	IsSteppedUp(ctx context.Context, details AccessDetails) (bool, error)
+	// Delete all of access- and refresh-tokens of user from Redis
+	RevokeSessions(ctx context.Context, userId int) error
+	// Delete single access token from Redis and from sessions of user
+	RevokeAccessToken(ctx context.Context, userId int, accessUuid string) error
+	// Create access token for OAuth2 client limited by scopes.
+	// Refresh token is not created, client requests new token instead
+	CreateClientToken(ctx context.Context, clientId string, scopes []string) (*ClientTokenResponse, error)
 }
 
 type AccessDetails interface {
 	GetUserId() int
 	GetRole() string
+	// Returns id of admin who impersonates user or 0
+	GetImpersonatorId() int
+	// Impersonated sessions are not allowed to change anything
+	IsReadOnly() bool
+	// Returns id of OAuth2 client or empty string for users
+	GetClientId() string
+	// Returns scopes of token. Scopes are set only for tokens of clients
+	GetScopes() []string
+	// Returns id of API key which was used for request or empty string
+	GetAPIKeyId() string
+	// Returns id of access token or empty string for API keys
+	GetAccessUuid() string
+	// Check permission by role and by scopes of token if token was issued
+	// with scopes
+	HasPermission(permission string) bool
+}
+
+type APIKeyVerifier interface {
+	// Returns owner and scopes of API key if key is valid and can be used
+	// from IP
+	VerifyAPIKey(ctx context.Context, key string, ip string) (*APIKeyOwner, error)
 }
 
 type AuthSettings struct {
-	Db           *sqlx.DB
-	RedisClient  *redis.Client
-	RedisContext context.Context
-	Secret       string
+	Db          *sqlx.DB
+	RedisClient *redis.Client
+	Secret      string
+	APIKeys     APIKeyVerifier
 }
 
 func NewService(settings AuthSettings) Authentication {
 	return &authService{
-		db:           settings.Db,
-		secret:       settings.Secret,
-		redisClient:  settings.RedisClient,
-		redisContext: settings.RedisContext,
+		db:          settings.Db,
+		secret:      settings.Secret,
+		redisClient: settings.RedisClient,
+		apiKeys:     settings.APIKeys,
 	}
 }
 
 type accessDetails struct {
-	Role   string
-	UserId int
+	Role           string
+	UserId         int
+	ImpersonatorId int
+	ClientId       string
+	Scopes         []string
+	APIKeyId       string
+	AccessUuid     string
 }
 
 func (ad *accessDetails) GetUserId() int {
@@ -70,23 +144,163 @@ func (ad *accessDetails) GetRole() string {
 	return ad.Role
 }
 
-func (auth *authService) CreateTokens(w http.ResponseWriter, userId int, role string) error {
+func (ad *accessDetails) GetImpersonatorId() int {
+	return ad.ImpersonatorId
+}
+
+func (ad *accessDetails) IsReadOnly() bool {
+	return ad.ImpersonatorId != 0
+}
+
+func (ad *accessDetails) GetClientId() string {
+	return ad.ClientId
+}
+
+func (ad *accessDetails) GetScopes() []string {
+	return ad.Scopes
+}
+
+func (ad *accessDetails) GetAPIKeyId() string {
+	return ad.APIKeyId
+}
+
+func (ad *accessDetails) GetAccessUuid() string {
+	return ad.AccessUuid
+}
+
+func (ad *accessDetails) HasPermission(permission string) bool {
+	// scopes narrow permissions of role, e.g. API key of user cannot get
+	// permission which was taken from user after key was created
+	if ad.Scopes != nil && !hasScope(ad.Scopes, permission) {
+		return false
+	}
+
+	return HasPermission(ad.Role, permission)
+}
+
+func (auth *authService) CreateTokens(ctx context.Context, w http.ResponseWriter, userId int) error {
+	td, err := auth.IssueTokens(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	auth.setTokenCookies(w, td)
+	return nil
+}
+
+func (auth *authService) IssueTokens(ctx context.Context, userId int) (*TokenDetails, error) {
+	role, err := auth.getUserRole(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	td, err := auth.generateTokens(userId, role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = auth.redisClient.Set(ctx, td.ATUuid, fmt.Sprint(userId), td.ATExpires.Sub(now)).Err()
+	if err != nil {
+		return nil, ErrRedisCannotSetAccessKey
+	}
+
+	err = auth.redisClient.Set(ctx, td.RTUuid, fmt.Sprint(userId), td.RTExpires.Sub(now)).Err()
+	if err != nil {
+		return nil, ErrRedisCannotSetRefreshKey
+	}
+
+	err = auth.storeSession(ctx, userId, td.ATUuid, td.RTUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.TokensIssued.Inc(metrics.TokenSession)
+	return td, nil
+}
+
+// Keys of tokens are stored to set of user, so all of them can be revoked.
+// Set lives as long as the longest token
+func (auth *authService) storeSession(ctx context.Context, userId int, keys ...string) error {
+	sessionsKey := KeyPrefixSessions + fmt.Sprint(userId)
+
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+
+	pipe := auth.redisClient.TxPipeline()
+	pipe.SAdd(ctx, sessionsKey, members...)
+	pipe.Expire(ctx, sessionsKey, TTLRefreshToken)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return ErrRedisCannotSetSessionKey
+	}
+
+	return nil
+}
+
+func (auth *authService) RevokeSessions(ctx context.Context, userId int) error {
+	sessionsKey := KeyPrefixSessions + fmt.Sprint(userId)
+
+	keys, err := auth.redisClient.SMembers(ctx, sessionsKey).Result()
 	if err != nil {
 		return err
 	}
 
-	err = auth.redisClient.Set(auth.redisContext, td.ATUuid, fmt.Sprint(userId), td.ATExpires.Sub(now)).Err()
+	return auth.redisClient.Del(ctx, append(keys, sessionsKey)...).Err()
+}
+
+func (auth *authService) RevokeAccessToken(ctx context.Context, userId int, accessUuid string) error {
+	pipe := auth.redisClient.TxPipeline()
+	pipe.Del(ctx, accessUuid)
+	pipe.SRem(ctx, KeyPrefixSessions+fmt.Sprint(userId), accessUuid)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (auth *authService) CreateImpersonationToken(ctx context.Context, adminId int, userId int) (*TokenDetails, error) {
+	role, err := auth.getUserRole(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == ROLE_ADMIN {
+		return nil, ErrCannotImpersonateAdmin
+	}
+
+	now := time.Now()
+	td := &TokenDetails{
+		ATUuid:    uuid.NewString(),
+		ATExpires: now.Add(TTLImpersonationToken),
+		Role:      role,
+	}
+
+	atClaims := jwt.MapClaims{}
+	atClaims["access_uuid"] = td.ATUuid
+	atClaims["role"] = role
+	atClaims["impersonator_id"] = adminId
+	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
+	td.AccessToken, err = at.SignedString([]byte(auth.secret))
+	if err != nil {
+		return nil, err
+	}
+
+	err = auth.redisClient.Set(ctx, td.ATUuid, fmt.Sprint(userId), td.ATExpires.Sub(now)).Err()
 	if err != nil {
-		return errors.New(ErrorRedisCannotSetAccessKey)
+		return nil, ErrRedisCannotSetAccessKey
 	}
 
-	err = auth.redisClient.Set(auth.redisContext, td.RTUuid, fmt.Sprint(userId), td.RTExpires.Sub(now)).Err()
+	err = auth.storeSession(ctx, userId, td.ATUuid)
 	if err != nil {
-		return errors.New(ErrorRedisCannotSetRefreshKey)
+		return nil, err
 	}
 
+	metrics.TokensIssued.Inc(metrics.TokenImpersonation)
+	return td, nil
+}
+
+func (auth *authService) setTokenCookies(w http.ResponseWriter, td *TokenDetails) {
 	accessCookie := &http.Cookie{
 		Name:     KeyAccessToken,
 		Value:    td.AccessToken,
@@ -107,62 +321,144 @@ func (auth *authService) CreateTokens(w http.ResponseWriter, userId int, role st
 	}
 	http.SetCookie(w, accessCookie)
 	http.SetCookie(w, refreshCookie)
-	return nil
 }
 
 func (auth *authService) GetTokenFromCookie(r *http.Request, key string) (*jwt.Token, error) {
 	cookieToken, err := r.Cookie(key)
 	if err != nil {
-		return nil, errors.New(ErrorEmptyToken)
+		return nil, ErrEmptyToken
+	}
+
+	return auth.ParseToken(cookieToken.Value)
+}
+
+func (auth *authService) GetTokenFromHeader(r *http.Request) (*jwt.Token, error) {
+	header := r.Header.Get(KeyAuthorizationHeader)
+	if header == "" {
+		return nil, ErrEmptyToken
+	}
+
+	scheme, value, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, BearerScheme) {
+		return nil, ErrNotValidAuthHeader
 	}
 
-	if cookieToken.Value == "" {
-		return nil, errors.New(ErrorEmptyToken)
+	return auth.ParseToken(strings.TrimSpace(value))
+}
+
+func (auth *authService) GetAccessToken(r *http.Request) (*jwt.Token, error) {
+	if r.Header.Get(KeyAuthorizationHeader) != "" {
+		return auth.GetTokenFromHeader(r)
+	}
+
+	return auth.GetTokenFromCookie(r, KeyAccessToken)
+}
+
+func (auth *authService) GetRequestAccessDetails(r *http.Request) (AccessDetails, error) {
+	ctx := r.Context()
+	if key := r.Header.Get(KeyAPIKeyHeader); key != "" {
+		if auth.apiKeys == nil {
+			return nil, ErrAPIKeysNotSupported
+		}
+
+		owner, err := auth.apiKeys.VerifyAPIKey(ctx, key, utils.ClientIP(r))
+		if err != nil {
+			return nil, err
+		}
+
+		return &accessDetails{
+			UserId:   owner.UserId,
+			Role:     owner.Role,
+			Scopes:   owner.Scopes,
+			APIKeyId: owner.KeyId,
+		}, nil
+	}
+
+	accessToken, err := auth.GetAccessToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.ExtractAccessMetaData(ctx, accessToken)
+}
+
+func (auth *authService) ParseToken(value string) (*jwt.Token, error) {
+	if value == "" {
+		return nil, ErrEmptyToken
 	}
 
-	token, err := auth.verifyToken(cookieToken.Value)
+	token, err := auth.verifyToken(value)
 	if err != nil {
 		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, errors.New(ErrorNotValidToken)
+		return nil, ErrNotValidToken
 	}
 
 	return token, nil
 }
 
-func (auth *authService) ExtractAccessMetaData(token *jwt.Token) (AccessDetails, error) {
+func (auth *authService) ExtractAccessMetaData(ctx context.Context, token *jwt.Token) (details AccessDetails, err error) {
+	defer func() {
+		if err != nil {
+			metrics.TokenFailures.Inc(metrics.TokenAccess)
+		}
+	}()
+
 	accessTokenDetails, err := auth.parseAccessToken(token)
 	if err != nil {
 		return nil, err
 	}
 
-	userIdString, err := auth.redisClient.Get(auth.redisContext, accessTokenDetails.ATUuid).Result()
+	// key is removed when token is revoked or expired
+	userIdString, err := auth.redisClient.Get(ctx, accessTokenDetails.ATUuid).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotValidToken
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// key of client token stores id of client instead of id of user
+	if accessTokenDetails.ClientId != "" {
+		if userIdString != accessTokenDetails.ClientId {
+			return nil, ErrNotValidToken
+		}
+
+		return &accessDetails{
+			Role:       accessTokenDetails.Role,
+			ClientId:   accessTokenDetails.ClientId,
+			Scopes:     accessTokenDetails.Scopes,
+			AccessUuid: accessTokenDetails.ATUuid,
+		}, nil
+	}
+
 	userId, err := strconv.Atoi(userIdString)
 	if err != nil {
 		return nil, err
 	}
 
 	return &accessDetails{
-		UserId: userId,
-		Role:   accessTokenDetails.Role,
+		UserId:         userId,
+		Role:           accessTokenDetails.Role,
+		ImpersonatorId: accessTokenDetails.ImpersonatorId,
+		AccessUuid:     accessTokenDetails.ATUuid,
 	}, nil
 }
 
-func (auth *authService) ExtractRefreshMetaData(token *jwt.Token) (AccessDetails, error) {
+func (auth *authService) ExtractRefreshMetaData(ctx context.Context, token *jwt.Token) (AccessDetails, error) {
 	refreshDetails, err := auth.parseRefreshToken(token)
 	if err != nil {
 		return nil, err
 	}
 
-	userIdString, err := auth.redisClient.Get(auth.redisContext, refreshDetails.RTUuid).Result()
+	userIdString, err := auth.redisClient.Get(ctx, refreshDetails.RTUuid).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotValidToken
+	}
 	if err != nil {
-		return nil, errors.New("cannot get value by key")
+		return nil, err
 	}
 
 	userId, err := strconv.Atoi(userIdString)
@@ -176,61 +472,250 @@ func (auth *authService) ExtractRefreshMetaData(token *jwt.Token) (AccessDetails
 	}, nil
 }
 
-func (auth *authService) RefreshToken(w http.ResponseWriter, refreshToken *jwt.Token) (AccessDetails, error) {
+func (auth *authService) RefreshToken(ctx context.Context, w http.ResponseWriter, refreshToken *jwt.Token) (AccessDetails, error) {
+	td, details, err := auth.RefreshTokenPair(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	auth.setTokenCookies(w, td)
+	return details, nil
+}
+
+func (auth *authService) RefreshTokenPair(ctx context.Context, refreshToken *jwt.Token) (td *TokenDetails, details AccessDetails, err error) {
+	defer func() {
+		if err != nil {
+			metrics.TokenFailures.Inc(metrics.TokenRefresh)
+		} else {
+			metrics.TokensRefreshed.Inc()
+		}
+	}()
 
 	refreshDetails, err := auth.parseRefreshToken(refreshToken)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	userIdString, err := auth.redisClient.Get(auth.redisContext, refreshDetails.RTUuid).Result()
+	// key is taken and deleted at once, so only one of concurrent requests
+	// with the same token rotates it and others get its result
+	userIdString, err := auth.redisClient.GetDel(ctx, refreshDetails.RTUuid).Result()
+	if errors.Is(err, redis.Nil) {
+		rotated, err := auth.getRotatedTokens(ctx, refreshDetails.RTUuid)
+		if err != nil {
+			return nil, nil, err
+		}
 
+		return rotated.Tokens, newSessionDetails(rotated.UserId, rotated.Tokens), nil
+	}
 	if err != nil {
-		return nil, errors.New("cannot get user_id from redis")
+		return nil, nil, err
 	}
 
 	userId, err := strconv.Atoi(userIdString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	err = auth.redisClient.Del(auth.redisContext, refreshDetails.RTUuid).Err()
+	td, err = auth.IssueTokens(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	err = auth.storeRotatedTokens(ctx, refreshDetails.RTUuid, rotatedTokens{UserId: userId, Tokens: td})
 	if err != nil {
-		return nil, errors.New("cannot delete key from redis")
+		return nil, nil, err
 	}
 
-	err = auth.CreateTokens(w, userId, refreshDetails.Role)
+	return td, newSessionDetails(userId, td), nil
+}
+
+func newSessionDetails(userId int, td *TokenDetails) AccessDetails {
+	return &accessDetails{
+		Role:       td.Role,
+		UserId:     userId,
+		AccessUuid: td.ATUuid,
+	}
+}
+
+// Keep tokens which replaced refresh token for TTLRefreshGrace. Key is
+// added to sessions of user, so it is revoked with them
+func (auth *authService) storeRotatedTokens(ctx context.Context, refreshUuid string, rotated rotatedTokens) error {
+	value, err := json.Marshal(rotated)
+	if err != nil {
+		return err
+	}
+
+	key := KeyPrefixRefreshGrace + refreshUuid
+	err = auth.redisClient.Set(ctx, key, value, TTLRefreshGrace).Err()
+	if err != nil {
+		return err
+	}
+
+	return auth.storeSession(ctx, rotated.UserId, key)
+}
+
+// Tokens which replaced refresh token. Concurrent request can still be
+// issuing them, so they are awaited for a short time
+func (auth *authService) getRotatedTokens(ctx context.Context, refreshUuid string) (*rotatedTokens, error) {
+	for attempt := 0; ; attempt++ {
+		value, err := auth.redisClient.Get(ctx, KeyPrefixRefreshGrace+refreshUuid).Bytes()
+		if err == nil {
+			var rotated rotatedTokens
+			err = json.Unmarshal(value, &rotated)
+			if err != nil {
+				return nil, err
+			}
+			return &rotated, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if attempt == ROTATION_WAIT_ATTEMPTS {
+			return nil, ErrNotValidToken
+		}
+
+		select {
+		case <-time.After(ROTATION_WAIT_INTERVAL):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (auth *authService) CreatePendingToken(ctx context.Context, userId int) (*PendingTokenResponse, error) {
+	pendingUuid := uuid.NewString()
+
+	claims := jwt.MapClaims{}
+	claims["pending_uuid"] = pendingUuid
+	claims["exp"] = time.Now().Add(TTLPendingToken).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(auth.secret))
 	if err != nil {
 		return nil, err
 	}
 
-	return &accessDetails{
-		Role:   refreshDetails.Role,
-		UserId: userId,
+	err = auth.redisClient.Set(ctx, KeyPrefixPending+pendingUuid, fmt.Sprint(userId), TTLPendingToken).Err()
+	if err != nil {
+		return nil, ErrRedisCannotSetPendingKey
+	}
+
+	return &PendingTokenResponse{
+		TwoFactorRequired: true,
+		PendingToken:      token,
+		ExpiresIn:         int(TTLPendingToken.Seconds()),
 	}, nil
 }
 
+func (auth *authService) CheckPendingToken(ctx context.Context, value string) (int, error) {
+	pendingUuid, err := auth.parsePendingToken(value)
+	if err != nil {
+		return 0, err
+	}
+
+	userIdString, err := auth.redisClient.Get(ctx, KeyPrefixPending+pendingUuid).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotValidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	attemptsKey := KeyPrefixPendingAttempts + pendingUuid
+	attempts, err := auth.redisClient.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	auth.redisClient.Expire(ctx, attemptsKey, TTLPendingToken)
+
+	if attempts > MaxPendingAttempts {
+		auth.redisClient.Del(ctx, KeyPrefixPending+pendingUuid, attemptsKey)
+		return 0, ErrTooManyAttempts
+	}
+
+	return strconv.Atoi(userIdString)
+}
+
+func (auth *authService) RevokePendingToken(ctx context.Context, value string) error {
+	pendingUuid, err := auth.parsePendingToken(value)
+	if err != nil {
+		return err
+	}
+
+	return auth.redisClient.Del(ctx, KeyPrefixPending+pendingUuid, KeyPrefixPendingAttempts+pendingUuid).Err()
+}
+
+func (auth *authService) StepUp(ctx context.Context, details AccessDetails) error {
+	if details.GetAccessUuid() == "" {
+		return ErrSessionRequired
+	}
+
+	return auth.redisClient.Set(ctx, KeyPrefixStepUp+details.GetAccessUuid(), time.Now().Unix(), TTLStepUp).Err()
+}
+
+func (auth *authService) IsSteppedUp(ctx context.Context, details AccessDetails) (bool, error) {
+	if details.GetAccessUuid() == "" {
+		return false, nil
+	}
+
+	exists, err := auth.redisClient.Exists(ctx, KeyPrefixStepUp+details.GetAccessUuid()).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+func (auth *authService) parsePendingToken(value string) (string, error) {
+	token, err := auth.ParseToken(value)
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims.Valid() != nil {
+		return "", ErrNotValidClaims
+	}
+
+	pendingUuid, ok := claims["pending_uuid"].(string)
+	if !ok {
+		return "", ErrNotIncludedUUIDClaim
+	}
+
+	return pendingUuid, nil
+}
+
 func (auth *authService) parseAccessToken(token *jwt.Token) (*AccessTokenDetails, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if ok && claims.Valid() == nil {
 		accessUuid, ok := claims["access_uuid"].(string)
 		if !ok {
-			return nil, errors.New(ErrorNotIncludedUUIDClaim)
+			return nil, ErrNotIncludedUUIDClaim
 		}
 
 		role, ok := claims["role"].(string)
 		if !ok {
-			return nil, errors.New(ErrorNotIncludedRoleClaim)
+			return nil, ErrNotIncludedRoleClaim
 		}
 
-		return &AccessTokenDetails{
-			ATUuid: accessUuid,
-			Role:   role,
-		}, nil
+		// claim is set only for impersonation tokens
+		impersonatorId, _ := claims["impersonator_id"].(float64)
+
+		details := &AccessTokenDetails{
+			ATUuid:         accessUuid,
+			Role:           role,
+			ImpersonatorId: int(impersonatorId),
+		}
+
+		// claims are set only for tokens of OAuth2 clients
+		if clientId, ok := claims["client_id"].(string); ok {
+			scope, _ := claims["scope"].(string)
+			details.ClientId = clientId
+			details.Scopes = strings.Fields(scope)
+		}
+
+		return details, nil
 	}
 
-	return nil, errors.New(ErrorNotValidClaims)
+	return nil, ErrNotValidClaims
 }
 
 func (auth *authService) parseRefreshToken(token *jwt.Token) (*RefreshTokenDetails, error) {
@@ -238,12 +723,12 @@ func (auth *authService) parseRefreshToken(token *jwt.Token) (*RefreshTokenDetai
 	if ok && claims.Valid() == nil {
 		refreshUuid, ok := claims["refresh_uuid"].(string)
 		if !ok {
-			return nil, errors.New(ErrorNotIncludedUUIDClaim)
+			return nil, ErrNotIncludedUUIDClaim
 		}
 
 		role, ok := claims["role"].(string)
 		if !ok {
-			return nil, errors.New(ErrorNotIncludedRoleClaim)
+			return nil, ErrNotIncludedRoleClaim
 		}
 
 		return &RefreshTokenDetails{
@@ -252,12 +737,26 @@ func (auth *authService) parseRefreshToken(token *jwt.Token) (*RefreshTokenDetai
 		}, nil
 	}
 
-	return nil, errors.New(ErrorNotValidClaims)
+	return nil, ErrNotValidClaims
+}
+
+func (auth *authService) getUserRole(ctx context.Context, userId int) (string, error) {
+	var role string
+	err := auth.db.GetContext(ctx, &role, "SELECT role FROM users WHERE id=$1", userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errs.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
 }
 
 func (auth *authService) generateTokens(userId int, role string) (*TokenDetails, error) {
 	now := time.Now()
 	td := &TokenDetails{
+		Role:      role,
 		ATUuid:    uuid.NewString(),
 		RTUuid:    uuid.NewString(),
 		ATExpires: now.Add(TTLAccessToken),
@@ -295,8 +794,10 @@ func (auth *authService) verifyToken(requestToken string) (*jwt.Token, error) {
 		}
 		return []byte(auth.secret), nil
 	})
+	// reason is not sent to client, expired, malformed and forged tokens
+	// are the same for it
 	if err != nil {
-		return nil, err
+		return nil, ErrNotValidToken
 	}
 
 	return token, nil
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Moranilt/billing/metrics"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+func (auth *authService) CreateClientToken(ctx context.Context, clientId string, scopes []string) (*ClientTokenResponse, error) {
+	now := time.Now()
+	accessUuid := uuid.NewString()
+	scope := strings.Join(scopes, " ")
+
+	claims := jwt.MapClaims{}
+	claims["access_uuid"] = accessUuid
+	claims["role"] = ROLE_SERVICE
+	claims["client_id"] = clientId
+	claims["scope"] = scope
+	claims["exp"] = now.Add(TTLClientToken).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(auth.secret))
+	if err != nil {
+		return nil, err
+	}
+
+	err = auth.redisClient.Set(ctx, accessUuid, clientId, TTLClientToken).Err()
+	if err != nil {
+		return nil, ErrRedisCannotSetAccessKey
+	}
+
+	metrics.TokensIssued.Inc(metrics.TokenClient)
+	return &ClientTokenResponse{
+		AccessToken: token,
+		TokenType:   BearerScheme,
+		ExpiresIn:   int(TTLClientToken.Seconds()),
+		Scope:       scope,
+	}, nil
+}
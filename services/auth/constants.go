@@ -1,22 +1,76 @@
 package auth
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
 
 const (
 	KeyAccessToken  = "access_token"
 	KeyRefreshToken = "refresh_token"
 
+	KeyAuthorizationHeader = "Authorization"
+	BearerScheme           = "Bearer"
+	KeyAPIKeyHeader        = "X-API-Key"
+
 	TTLRefreshToken = time.Hour * 24 * 7
 	TTLAccessToken  = time.Minute * 15
 
-	ErrorEmptyToken               = "token was not provided"
-	ErrorNotValidToken            = "token is not valid"
-	ErrorRedisCannotSetAccessKey  = "cannot set new access_token key"
-	ErrorRedisCannotSetRefreshKey = "cannot set new refresh_token key"
-	ErrorNotIncludedUUIDClaim     = "not included uuid claim"
-	ErrorNotIncludedRoleClaim     = "not included role claim access"
-	ErrorNotValidClaims           = "not valid claims"
+	TTLImpersonationToken = time.Minute * 15
+	TTLPendingToken       = time.Minute * 5
+	TTLStepUp             = time.Minute * 5
+	TTLClientToken        = time.Hour
+	// Old refresh token gives the same new tokens during this time after
+	// rotation, so parallel requests of browser tabs do not log user out
+	TTLRefreshGrace = time.Second * 10
+
+	// Request with rotated refresh token waits for tokens issued by
+	// concurrent request
+	ROTATION_WAIT_ATTEMPTS = 5
+	ROTATION_WAIT_INTERVAL = time.Millisecond * 50
+
+	KeyPrefixPending         = "pending_2fa:"
+	KeyPrefixPendingAttempts = "pending_2fa_attempts:"
+	KeyPrefixStepUp          = "step_up:"
+	KeyPrefixSessions        = "user_sessions:"
+	KeyPrefixRefreshGrace    = "refresh_grace:"
+
+	MaxPendingAttempts = 5
+
+	ROLE_ADMIN    = "admin"
+	ROLE_MERCHANT = "merchant"
+	ROLE_USER     = "user"
+	ROLE_VISITOR  = "visitor"
+	// role of OAuth2 clients, permissions of clients are defined by scopes
+	ROLE_SERVICE = "service"
+
+	PermissionUserRead           = "user:read"
+	PermissionCardsRead          = "cards:read"
+	PermissionCardsIssue         = "cards:issue"
+	PermissionTransactionsRefund = "transactions:refund"
+	PermissionAPIKeysManage      = "api_keys:manage"
+)
 
-	ROLE_USER    = "user"
-	ROLE_VISITOR = "visitor"
+var (
+	ErrEmptyToken               = errs.Unauthorized("token_missing", "token was not provided")
+	ErrNotValidToken            = errs.Unauthorized("token_not_valid", "token is not valid")
+	ErrRedisCannotSetAccessKey  = errors.New("cannot set new access_token key")
+	ErrRedisCannotSetRefreshKey = errors.New("cannot set new refresh_token key")
+	ErrNotIncludedUUIDClaim     = errs.Unauthorized("token_claims_not_valid", "not included uuid claim")
+	ErrNotIncludedRoleClaim     = errs.Unauthorized("token_claims_not_valid", "not included role claim access")
+	ErrNotValidClaims           = errs.Unauthorized("token_claims_not_valid", "not valid claims")
+	ErrNotValidAuthHeader       = errs.Unauthorized("auth_header_not_valid", "authorization header is not valid")
+	ErrPermissionDenied         = errs.Forbidden("permission_denied", "permission denied")
+	ErrCannotImpersonateAdmin   = errs.Forbidden("cannot_impersonate_admin", "cannot impersonate admin")
+	ErrReadOnlySession          = errs.Forbidden("session_read_only", "session is read-only")
+	ErrRedisCannotSetPendingKey = errors.New("cannot set new pending token key")
+	ErrTooManyAttempts          = errs.TooManyRequests("too_many_attempts", "too many attempts")
+	ErrRedisCannotSetSessionKey = errors.New("cannot store session of user")
+	ErrAPIKeysNotSupported      = errs.Unauthorized("api_keys_not_supported", "API keys are not supported")
+	ErrSessionRequired          = errs.Forbidden("session_required", "session of user is required")
+	ErrStepUpRequired           = errs.Forbidden("step_up_required", "two-factor verification is required")
+	ErrOriginNotAllowed         = errs.Forbidden("origin_not_allowed", "origin of request is not allowed")
+	ErrNoAccessDetails          = errors.New("access details were not set by middleware")
 )
@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+type accessDetailsKey struct{}
+
+// Context with principal of request, it is set by middleware, so
+// handlers do not parse token again
+func NewContext(ctx context.Context, details AccessDetails) context.Context {
+	return context.WithValue(ctx, accessDetailsKey{}, details)
+}
+
+// Principal of request which was set by NewContext
+func FromContext(ctx context.Context) (AccessDetails, bool) {
+	details, ok := ctx.Value(accessDetailsKey{}).(AccessDetails)
+	return details, ok
+}
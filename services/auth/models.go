@@ -8,8 +8,11 @@ type Tokens struct {
 }
 
 type AccessTokenDetails struct {
-	ATUuid string
-	Role   string
+	ATUuid         string
+	Role           string
+	ImpersonatorId int
+	ClientId       string
+	Scopes         []string
 }
 
 type RefreshTokenDetails struct {
@@ -17,11 +20,79 @@ type RefreshTokenDetails struct {
 	Role   string
 }
 
+// Tokens which replaced refresh token. They are stored for TTLRefreshGrace
+// after rotation
+type rotatedTokens struct {
+	UserId int
+	Tokens *TokenDetails
+}
+
 type TokenDetails struct {
 	AccessToken  string
 	RefreshToken string
 	ATUuid       string
 	RTUuid       string
+	Role         string
 	ATExpires    time.Time
 	RTExpires    time.Time
 }
+
+type ImpersonationResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokensResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+func NewTokensResponse(td *TokenDetails) TokensResponse {
+	now := time.Now()
+	return TokensResponse{
+		AccessToken:      td.AccessToken,
+		RefreshToken:     td.RefreshToken,
+		TokenType:        BearerScheme,
+		ExpiresIn:        int(td.ATExpires.Sub(now).Seconds()),
+		RefreshExpiresIn: int(td.RTExpires.Sub(now).Seconds()),
+	}
+}
+
+type PendingTokenResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	PendingToken      string `json:"pending_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type PendingTokenRequest struct {
+	PendingToken string `json:"pending_token" validate:"required"`
+	Code         string `json:"code" validate:"required,max=32"`
+}
+
+type StepUpResponse struct {
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Response of token endpoint as it is described in RFC 6749
+type ClientTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type APIKeyOwner struct {
+	KeyId  string
+	UserId int
+	Role   string
+	Scopes []string
+}
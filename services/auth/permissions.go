@@ -0,0 +1,61 @@
+package auth
+
+// Permissions granted to every role. Role of user is stored in DB,
+// list of permissions for the role is defined here
+var rolePermissions = map[string][]string{
+	ROLE_ADMIN: {
+		PermissionUserRead,
+		PermissionCardsRead,
+		PermissionCardsIssue,
+		PermissionTransactionsRefund,
+		PermissionAPIKeysManage,
+	},
+	ROLE_MERCHANT: {
+		PermissionUserRead,
+		PermissionCardsRead,
+		PermissionCardsIssue,
+		PermissionTransactionsRefund,
+		PermissionAPIKeysManage,
+	},
+	ROLE_USER: {
+		PermissionUserRead,
+		PermissionCardsRead,
+		PermissionCardsIssue,
+	},
+	ROLE_VISITOR: {},
+	// clients are limited by scopes of token, role allows every permission
+	// which can be granted to clients
+	ROLE_SERVICE: {
+		PermissionUserRead,
+		PermissionCardsRead,
+		PermissionCardsIssue,
+		PermissionTransactionsRefund,
+	},
+}
+
+// Check that role has permission
+func HasPermission(role string, permission string) bool {
+	return hasScope(rolePermissions[role], permission)
+}
+
+// List of all of permissions which can be granted to clients as scopes
+var Permissions = []string{
+	PermissionUserRead,
+	PermissionCardsRead,
+	PermissionCardsIssue,
+	PermissionTransactionsRefund,
+}
+
+// Check that permission exists
+func IsPermission(permission string) bool {
+	return hasScope(Permissions, permission)
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
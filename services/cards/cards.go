@@ -1,8 +1,10 @@
 package cards
 
 import (
+	"context"
 	"encoding/json"
 
+	"github.com/Moranilt/billing/metrics"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -11,15 +13,19 @@ type Cards struct {
 }
 
 type CardsMethods interface {
-	GetCards(userId int) ([]Card, error)
-	Create(userId int, cardState CardCreate) (*Card, error)
+	GetCards(ctx context.Context, userId int) ([]Card, error)
+	Create(ctx context.Context, userId int, cardState CardCreate) (*Card, error)
+	// Get full number and CVC of card which belongs to user
+	Reveal(ctx context.Context, userId int, cardId string) (*CardSecret, error)
+	// Number and total amount of transactions by state
+	TransactionVolume(ctx context.Context) ([]TransactionVolume, error)
 }
 
 func NewService(db *sqlx.DB) CardsMethods {
 	return &Cards{db: db}
 }
 
-func (c *Cards) Create(userId int, cardState CardCreate) (*Card, error) {
+func (c *Cards) Create(ctx context.Context, userId int, cardState CardCreate) (*Card, error) {
 	var card Card
 
 	query := `INSERT INTO cards 
@@ -28,7 +34,7 @@ func (c *Cards) Create(userId int, cardState CardCreate) (*Card, error) {
 	RETURNING id, number, mask, cvc, state_id`
 
 	var newCard Card
-	err := c.db.QueryRowx(
+	err := c.db.QueryRowxContext(ctx,
 		query,
 		userId,
 		cardState.Number,
@@ -40,15 +46,15 @@ func (c *Cards) Create(userId int, cardState CardCreate) (*Card, error) {
 		return nil, err
 	}
 
+	metrics.CardsIssued.Inc()
 	return &card, nil
 }
 
-func (c *Cards) GetCards(userId int) ([]Card, error) {
+func (c *Cards) GetCards(ctx context.Context, userId int) ([]Card, error) {
 	var cards []Card
-	rows, err := c.db.Queryx(`SELECT 
-	cards.number as number,
+	rows, err := c.db.QueryxContext(ctx, `SELECT 
+	cards.id as id,
 	cards.mask as mask,
-	cards.cvc as cvc,
 	cards.balance as balance,
 	json_build_object(
 		'id', cs.id,
@@ -77,9 +83,8 @@ func (c *Cards) GetCards(userId int) ([]Card, error) {
 		}
 
 		card := Card{
-			Number:  dirtyCard.Number,
+			Id:      dirtyCard.Id,
 			Mask:    dirtyCard.Mask,
-			CVC:     dirtyCard.CVC,
 			Balance: dirtyCard.Balance,
 			State:   state,
 		}
@@ -89,3 +94,32 @@ func (c *Cards) GetCards(userId int) ([]Card, error) {
 
 	return cards, nil
 }
+
+func (c *Cards) Reveal(ctx context.Context, userId int, cardId string) (*CardSecret, error) {
+	var secret CardSecret
+	err := c.db.GetContext(ctx, &secret, `SELECT id, number, cvc, until_date
+	FROM cards
+	WHERE id=$1 AND user_id=$2`, cardId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+func (c *Cards) TransactionVolume(ctx context.Context) ([]TransactionVolume, error) {
+	var volume []TransactionVolume
+	err := c.db.SelectContext(ctx, &volume, `SELECT
+		ts.description AS state,
+		count(t.id) AS count,
+		COALESCE(sum(t.summ), 0) AS amount
+	FROM transaction_states ts
+	LEFT JOIN transactions t ON t.state_id = ts.id
+	GROUP BY ts.id, ts.description
+	ORDER BY ts.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return volume, nil
+}
@@ -1,5 +1,10 @@
 package cards
 
 const (
-	DEFAULT_CARD_STATE = 1
+	CARD_STATE_NOT_ACTIVATED = 0
+	CARD_STATE_ACTIVATED     = 1
+	CARD_STATE_BLOCKED       = 2
+	CARD_STATE_OUTDATED      = 3
+
+	DEFAULT_CARD_STATE = CARD_STATE_ACTIVATED
 )
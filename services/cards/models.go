@@ -1,17 +1,17 @@
 package cards
 
 type Card struct {
-	Number  string    `json:"number" db:"number"`
+	Id      string    `json:"id" db:"id"`
+	Number  string    `json:"number,omitempty" db:"number"`
 	Mask    string    `json:"mask" db:"mask"`
-	CVC     int       `json:"cvc" db:"cvc"`
+	CVC     int       `json:"cvc,omitempty" db:"cvc"`
 	Balance float64   `json:"balance" db:"balance"`
 	State   CardState `json:"state" db:"state"`
 }
 
 type CardSelect struct {
-	Number  string  `json:"number" db:"number"`
+	Id      string  `json:"id" db:"id"`
 	Mask    string  `json:"mask" db:"mask"`
-	CVC     int     `json:"cvc" db:"cvc"`
 	Balance float64 `json:"balance" db:"balance"`
 	State   []byte  `json:"state" db:"state"`
 }
@@ -22,7 +22,20 @@ type CardState struct {
 }
 
 type CardCreate struct {
-	Number string `json:"number" db:"number"`
-	Mask   string `json:"mask" db:"mask"`
-	CVC    int    `json:"cvc" db:"cvc"`
+	Number string `json:"number" db:"number" validate:"required,luhn"`
+	Mask   string `json:"mask" db:"mask" validate:"required,max=19"`
+	CVC    int    `json:"cvc" db:"cvc" validate:"max=9999"`
+}
+
+type CardSecret struct {
+	Id        string  `json:"id" db:"id"`
+	Number    string  `json:"number" db:"number"`
+	CVC       int     `json:"cvc" db:"cvc"`
+	UntilDate *string `json:"until_date" db:"until_date"`
+}
+
+type TransactionVolume struct {
+	State  string  `json:"state" db:"state"`
+	Count  int64   `json:"count" db:"count"`
+	Amount float64 `json:"amount" db:"amount"`
 }
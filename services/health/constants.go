@@ -0,0 +1,25 @@
+package health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	CheckPostgres = "postgres"
+	CheckRedis    = "redis"
+
+	// Time limit of one readiness check, so slow dependency does not
+	// block orchestrator probes
+	CHECK_TIMEOUT = time.Second * 2
+)
+
+var (
+	ErrShuttingDown = errs.New(http.StatusServiceUnavailable, "shutting_down", "service is shutting down")
+	ErrNotReady     = errs.New(http.StatusServiceUnavailable, "not_ready", "service is not ready")
+)
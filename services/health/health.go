@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+)
+
+type healthService struct {
+	db           *sqlx.DB
+	redisClient  *redis.Client
+	shuttingDown atomic.Bool
+}
+
+type HealthMethods interface {
+	// Report that process is alive. Dependencies are not checked
+	Live() Report
+	// Ping every dependency and report its status and latency. Service
+	// is not ready when one of dependencies is down or when it is
+	// shutting down
+	Ready(ctx context.Context) Report
+	// Mark service as shutting down, readiness fails after this call
+	ShuttingDown()
+}
+
+type HealthSettings struct {
+	Db          *sqlx.DB
+	RedisClient *redis.Client
+}
+
+func NewService(settings HealthSettings) HealthMethods {
+	return &healthService{
+		db:          settings.Db,
+		redisClient: settings.RedisClient,
+	}
+}
+
+func (h *healthService) Live() Report {
+	return Report{
+		Status:       StatusUp,
+		ShuttingDown: h.shuttingDown.Load(),
+	}
+}
+
+func (h *healthService) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, CHECK_TIMEOUT)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		CheckPostgres: h.db.PingContext,
+		CheckRedis: func(ctx context.Context) error {
+			return h.redisClient.Ping(ctx).Err()
+		},
+	}
+
+	report := Report{
+		Status:       StatusUp,
+		ShuttingDown: h.shuttingDown.Load(),
+		Checks:       make(map[string]Check, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, ping := range checks {
+		wg.Add(1)
+		go func(name string, ping func(context.Context) error) {
+			defer wg.Done()
+			check := runCheck(ctx, ping)
+			mu.Lock()
+			report.Checks[name] = check
+			mu.Unlock()
+		}(name, ping)
+	}
+	wg.Wait()
+
+	if report.ShuttingDown {
+		report.Status = StatusDown
+	}
+	for _, check := range report.Checks {
+		if check.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func (h *healthService) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+func runCheck(ctx context.Context, ping func(context.Context) error) Check {
+	start := time.Now()
+	err := ping(ctx)
+	check := Check{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		check.Status = StatusDown
+		check.Error = err
+	}
+	return check
+}
@@ -0,0 +1,15 @@
+package health
+
+type Check struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	// Errors of drivers can contain addresses and names of hosts, so they
+	// are logged and not sent in response
+	Error error `json:"-"`
+}
+
+type Report struct {
+	Status       string           `json:"status"`
+	ShuttingDown bool             `json:"shutting_down"`
+	Checks       map[string]Check `json:"checks,omitempty"`
+}
@@ -0,0 +1,19 @@
+package lockout
+
+import (
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	KeyPrefixFailures = "login_failures:"
+	KeyPrefixBackoff  = "login_backoff:"
+	KeyPrefixLock     = "login_lock:"
+
+	UnlockReasonOTP           = "otp"
+	UnlockReasonPasswordReset = "password_reset"
+)
+
+var (
+	ErrAccountLocked   = errs.TooManyRequests("account_locked", "account is temporarily locked")
+	ErrTooManyAttempts = errs.TooManyRequests("too_many_login_attempts", "too many login attempts, try again later")
+)
@@ -0,0 +1,184 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+)
+
+type lockoutService struct {
+	db          *sqlx.DB
+	redisClient *redis.Client
+	config      config.Lockout
+}
+
+type LockoutMethods interface {
+	// Check that login attempt is allowed right now. Returns ErrorAccountLocked
+	// or ErrorTooManyAttempts with duration to wait if it is not
+	Check(ctx context.Context, attempt Attempt) (time.Duration, error)
+	// Count failed attempt for account and IP. Next attempt is delayed
+	// exponentially and account is locked after MaxAccountFailures
+	Fail(ctx context.Context, attempt Attempt) error
+	// Reset failed attempts of account after successful login
+	Succeed(ctx context.Context, attempt Attempt) error
+	// Check that account of user is locked
+	IsLocked(ctx context.Context, userId int) (bool, error)
+	// Remove lock of account and record the reason of unlock
+	Unlock(ctx context.Context, userId int, reason string) error
+}
+
+type LockoutSettings struct {
+	Db          *sqlx.DB
+	RedisClient *redis.Client
+	Config      config.Lockout
+}
+
+func NewService(settings LockoutSettings) LockoutMethods {
+	return &lockoutService{
+		db:          settings.Db,
+		redisClient: settings.RedisClient,
+		config:      settings.Config,
+	}
+}
+
+func (l *lockoutService) Check(ctx context.Context, attempt Attempt) (time.Duration, error) {
+	account := accountKey(attempt)
+
+	accountLock, err := l.redisClient.PTTL(ctx, KeyPrefixLock+account).Result()
+	if err != nil {
+		return 0, err
+	}
+	if accountLock > 0 {
+		return accountLock, ErrAccountLocked
+	}
+
+	var wait time.Duration
+	for _, key := range []string{KeyPrefixLock + ipKey(attempt), KeyPrefixBackoff + account} {
+		ttl, err := l.redisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+
+	if wait > 0 {
+		return wait, ErrTooManyAttempts
+	}
+
+	return 0, nil
+}
+
+func (l *lockoutService) Fail(ctx context.Context, attempt Attempt) error {
+	account := accountKey(attempt)
+	ip := ipKey(attempt)
+
+	pipe := l.redisClient.TxPipeline()
+	accountFailures := pipe.Incr(ctx, KeyPrefixFailures+account)
+	pipe.Expire(ctx, KeyPrefixFailures+account, l.config.FailuresWindow)
+	ipFailures := pipe.Incr(ctx, KeyPrefixFailures+ip)
+	pipe.Expire(ctx, KeyPrefixFailures+ip, l.config.FailuresWindow)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ipFailures.Val() >= int64(l.config.MaxIPFailures) {
+		err = l.redisClient.Set(ctx, KeyPrefixLock+ip, 1, l.config.LockDuration).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	if accountFailures.Val() >= int64(l.config.MaxAccountFailures) {
+		return l.lock(ctx, attempt)
+	}
+
+	delay := l.backoff(accountFailures.Val())
+	if delay <= 0 {
+		return nil
+	}
+
+	return l.redisClient.Set(ctx, KeyPrefixBackoff+account, 1, delay).Err()
+}
+
+func (l *lockoutService) Succeed(ctx context.Context, attempt Attempt) error {
+	account := accountKey(attempt)
+	return l.redisClient.Del(ctx, KeyPrefixFailures+account, KeyPrefixBackoff+account).Err()
+}
+
+func (l *lockoutService) IsLocked(ctx context.Context, userId int) (bool, error) {
+	exists, err := l.redisClient.Exists(ctx, KeyPrefixLock+accountKey(Attempt{UserId: userId})).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+func (l *lockoutService) Unlock(ctx context.Context, userId int, reason string) error {
+	account := accountKey(Attempt{UserId: userId})
+	err := l.redisClient.Del(ctx, KeyPrefixLock+account, KeyPrefixFailures+account, KeyPrefixBackoff+account).Err()
+	if err != nil {
+		return err
+	}
+
+	_, err = l.db.ExecContext(ctx, `UPDATE account_lockouts
+	SET unlocked_at=CURRENT_TIMESTAMP, unlock_reason=$2
+	WHERE user_id=$1 AND unlocked_at IS NULL AND locked_until > CURRENT_TIMESTAMP`, userId, reason)
+	return err
+}
+
+func (l *lockoutService) lock(ctx context.Context, attempt Attempt) error {
+	account := accountKey(attempt)
+
+	pipe := l.redisClient.TxPipeline()
+	pipe.Set(ctx, KeyPrefixLock+account, 1, l.config.LockDuration)
+	pipe.Del(ctx, KeyPrefixFailures+account, KeyPrefixBackoff+account)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	// logins which do not belong to any user are locked only in Redis
+	if attempt.UserId == 0 {
+		return nil
+	}
+
+	_, err = l.db.ExecContext(ctx, `INSERT INTO account_lockouts (user_id, ip, locked_until)
+	VALUES($1, $2, $3)`, attempt.UserId, attempt.IP, time.Now().Add(l.config.LockDuration))
+	return err
+}
+
+// Delay before next attempt after given count of failed attempts
+func (l *lockoutService) backoff(failures int64) time.Duration {
+	delay := l.config.BackoffBase
+	for i := int64(1); i < failures; i++ {
+		delay *= 2
+		if delay >= l.config.BackoffMax {
+			return l.config.BackoffMax
+		}
+	}
+
+	return delay
+}
+
+// Attempts of existing user are counted by id, so email and phone of
+// the same user share counters
+func accountKey(attempt Attempt) string {
+	if attempt.UserId != 0 {
+		return fmt.Sprintf("user:%d", attempt.UserId)
+	}
+
+	return "login:" + strings.ToLower(attempt.Login)
+}
+
+func ipKey(attempt Attempt) string {
+	return "ip:" + attempt.IP
+}
@@ -0,0 +1,17 @@
+package lockout
+
+type Attempt struct {
+	// Id of user if login belongs to existing user, otherwise 0
+	UserId int
+	Login  string
+	IP     string
+}
+
+type UnlockRequest struct {
+	Login string `json:"login" validate:"required,max=255"`
+}
+
+type UnlockVerifyRequest struct {
+	Login string `json:"login" validate:"required,max=255"`
+	Code  string `json:"code" validate:"required,len=6,digits"`
+}
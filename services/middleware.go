@@ -1,55 +1,322 @@
 package services
 
 import (
-	"io"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Moranilt/billing/config"
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/logger"
 	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/services/ratelimit"
+	"github.com/Moranilt/billing/utils"
+	"github.com/Moranilt/rou"
 	"github.com/jmoiron/sqlx"
 )
 
 type Middleware struct {
-	db   *sqlx.DB
-	auth auth.Authentication
+	db      *sqlx.DB
+	auth    auth.Authentication
+	logger  logger.LoggerWriter
+	origins utils.Origins
+	limiter ratelimit.LimiterMethods
 }
 
-func NewMiddlewareService(db *sqlx.DB, auth auth.Authentication) *Middleware {
-	return &Middleware{db: db, auth: auth}
+type MiddlewareSettings struct {
+	Db     *sqlx.DB
+	Auth   auth.Authentication
+	Logger logger.LoggerWriter
+	// Origins of web apps which can send requests with cookies of users
+	Origins utils.Origins
+	Limiter ratelimit.LimiterMethods
 }
 
+func NewMiddlewareService(settings MiddlewareSettings) *Middleware {
+	return &Middleware{
+		db:      settings.Db,
+		auth:    settings.Auth,
+		logger:  settings.Logger,
+		origins: settings.Origins,
+		limiter: settings.Limiter,
+	}
+}
+
+// Write error in common response format and stop request. Internal
+// errors are logged, client gets only common message instead of them
+func (mw *Middleware) deny(w http.ResponseWriter, r *http.Request, err error) bool {
+	if errs.IsInternal(err) {
+		mw.logger.WithContext(r.Context()).Error("request failed", logger.Err(err))
+	}
+	utils.WriteError(w, r, err)
+	return false
+}
+
+// Resolves principal of request by API key, bearer token or cookies and
+// puts it to context of request, handlers get it by auth.FromContext.
+// Missing access cookie is restored by refresh cookie
 func (mw *Middleware) AuthorizedUser(w http.ResponseWriter, r *http.Request) bool {
-	if r.Header.Get("Authorization") == "" {
-		io.WriteString(w, "Authorization header was not provided")
-		return false
+	accessDetails, err := mw.authenticate(w, r)
+	if err != nil {
+		return mw.deny(w, r, err)
+	}
+
+	// router passes the same request to next middlewares and handler
+	*r = *r.WithContext(auth.NewContext(r.Context(), accessDetails))
+	logPrincipal(r, accessDetails)
+	return mw.allowedForSession(w, r, accessDetails)
+}
+
+func (mw *Middleware) authenticate(w http.ResponseWriter, r *http.Request) (auth.AccessDetails, error) {
+	ctx := r.Context()
+	if r.Header.Get(auth.KeyAPIKeyHeader) != "" {
+		return mw.auth.GetRequestAccessDetails(r)
+	}
+
+	if r.Header.Get(auth.KeyAuthorizationHeader) != "" {
+		accessToken, err := mw.auth.GetTokenFromHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return mw.auth.ExtractAccessMetaData(ctx, accessToken)
+	}
+
+	err := mw.checkOrigin(r)
+	if err != nil {
+		return nil, err
 	}
+
 	accessToken, err := mw.auth.GetTokenFromCookie(r, auth.KeyAccessToken)
+	if err == nil {
+		accessDetails, err := mw.auth.ExtractAccessMetaData(ctx, accessToken)
+		// key of access token expires in Redis together with cookie, so
+		// browser can send cookie which has just expired
+		if !errors.Is(err, auth.ErrNotValidToken) {
+			return accessDetails, err
+		}
+	} else if !errors.Is(err, auth.ErrEmptyToken) {
+		return nil, err
+	}
 
-	if err != nil && err.Error() == auth.ErrorNotValidToken {
-		io.WriteString(w, err.Error())
-		return false
+	// new cookies are sent with response and principal of new tokens is
+	// passed to handler, so request does not fail while tokens are renewed
+	refreshToken, err := mw.auth.GetTokenFromCookie(r, auth.KeyRefreshToken)
+	if err != nil {
+		return nil, err
 	}
 
-	if err != nil && err.Error() == auth.ErrorEmptyToken {
-		refreshToken, err := mw.auth.GetTokenFromCookie(r, auth.KeyRefreshToken)
+	return mw.auth.RefreshToken(ctx, w, refreshToken)
+}
+
+// Browser sends cookies with requests of any site which user opens, so
+// requests with cookies which change data are accepted only from origin of
+// API or trusted origins of web apps. Requests with tokens in headers
+// cannot be forged by other sites and are not checked
+func (mw *Middleware) checkOrigin(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	if !hasCookie(r, auth.KeyAccessToken) && !hasCookie(r, auth.KeyRefreshToken) {
+		return nil
+	}
+
+	origin := utils.RequestOrigin(r)
+	if origin != "" && (utils.IsSameOrigin(r, origin) || mw.origins.Trusts(origin)) {
+		return nil
+	}
+	return auth.ErrOriginNotAllowed
+}
+
+func hasCookie(r *http.Request, name string) bool {
+	_, err := r.Cookie(name)
+	return err == nil
+}
+
+// Principal which was set by AuthorizedUser. Other middlewares should be
+// added after it
+func accessDetailsFromRequest(r *http.Request) (auth.AccessDetails, error) {
+	accessDetails, ok := auth.FromContext(r.Context())
+	if !ok {
+		return nil, auth.ErrNoAccessDetails
+	}
+	return accessDetails, nil
+}
+
+// Add authorized principal to log fields of request
+func logPrincipal(r *http.Request, accessDetails auth.AccessDetails) {
+	var fields []logger.Field
+	if accessDetails.GetUserId() != 0 {
+		fields = append(fields, logger.Int("user_id", accessDetails.GetUserId()))
+	}
+	if accessDetails.GetImpersonatorId() != 0 {
+		fields = append(fields, logger.Int("impersonator_id", accessDetails.GetImpersonatorId()))
+	}
+	if accessDetails.GetClientId() != "" {
+		fields = append(fields, logger.String("client_id", accessDetails.GetClientId()))
+	}
+	if accessDetails.GetAPIKeyId() != "" {
+		fields = append(fields, logger.String("api_key_id", accessDetails.GetAPIKeyId()))
+	}
+	logger.AddFields(r.Context(), fields...)
+}
+
+// Read-only sessions are allowed to use only safe methods
+func (mw *Middleware) allowedForSession(w http.ResponseWriter, r *http.Request, accessDetails auth.AccessDetails) bool {
+	if !accessDetails.IsReadOnly() {
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return mw.deny(w, r, auth.ErrReadOnlySession)
+}
+
+// Returns middleware which allows request only if role of authorized user
+// has given permission
+func (mw *Middleware) RequirePermission(permission string) rou.MiddlewareFunction {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		accessDetails, err := accessDetailsFromRequest(r)
 		if err != nil {
-			io.WriteString(w, err.Error())
-			return false
+			return mw.deny(w, r, err)
 		}
 
-		_, err = mw.auth.RefreshToken(w, refreshToken)
+		if !accessDetails.HasPermission(permission) {
+			return mw.deny(w, r, auth.ErrPermissionDenied)
+		}
+
+		return true
+	}
+}
+
+// Returns middleware which allows request only if session of user has
+// given role. API keys and clients do not get role of owner
+func (mw *Middleware) RequireRole(role string) rou.MiddlewareFunction {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		accessDetails, err := accessDetailsFromRequest(r)
 		if err != nil {
-			io.WriteString(w, err.Error())
-			return false
+			return mw.deny(w, r, err)
+		}
+
+		if accessDetails.GetRole() != role || accessDetails.IsReadOnly() ||
+			accessDetails.GetAPIKeyId() != "" || accessDetails.GetClientId() != "" {
+			return mw.deny(w, r, auth.ErrPermissionDenied)
 		}
 
 		return true
 	}
+}
+
+// Allows request only if session was recently verified by second factor
+func (mw *Middleware) RequireStepUp(w http.ResponseWriter, r *http.Request) bool {
+	accessDetails, err := accessDetailsFromRequest(r)
+	if err != nil {
+		return mw.deny(w, r, err)
+	}
+
+	steppedUp, err := mw.auth.IsSteppedUp(r.Context(), accessDetails)
+	if err != nil {
+		return mw.deny(w, r, err)
+	}
+
+	if !steppedUp {
+		return mw.deny(w, r, auth.ErrStepUpRequired)
+	}
+
+	return true
+}
 
-	_, err = mw.auth.ExtractAccessMetaData(accessToken)
+// Allows request only for sessions of users. Requests with API keys
+// or tokens of OAuth2 clients are not allowed
+func (mw *Middleware) RequireSession(w http.ResponseWriter, r *http.Request) bool {
+	accessDetails, err := accessDetailsFromRequest(r)
 	if err != nil {
-		io.WriteString(w, err.Error())
-		return false
+		return mw.deny(w, r, err)
+	}
+
+	if accessDetails.GetAPIKeyId() != "" || accessDetails.GetClientId() != "" {
+		return mw.deny(w, r, auth.ErrSessionRequired)
 	}
 
 	return true
 }
+
+// Returns middleware which limits requests by policy of route. Prefix of
+// version of API is removed from route, so the same route of every
+// version has one limit. Should be added after AuthorizedUser, so requests
+// of principal are counted together, and after RateLimitIP
+func (mw *Middleware) RateLimit(prefix string) rou.MiddlewareFunction {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		route := strings.TrimPrefix(utils.Route(r.Context()), prefix)
+		policy, ok := mw.limiter.Policy(route)
+		if !ok {
+			return true
+		}
+
+		result := mw.limiter.Allow(r.Context(), policy, rateLimitKey(r, policy))
+		header := w.Header()
+		header.Set(ratelimit.KeyLimitHeader, strconv.Itoa(result.Limit))
+		header.Set(ratelimit.KeyRemainingHeader, strconv.Itoa(result.Remaining))
+		header.Set(ratelimit.KeyResetHeader, seconds(result.Reset))
+		header.Set(ratelimit.KeyPolicyHeader, rateLimitPolicy(policy))
+		if result.Allowed {
+			return true
+		}
+
+		header.Set("Retry-After", seconds(result.RetryAfter))
+		return mw.deny(w, r, ratelimit.ErrRateLimited)
+	}
+}
+
+// Limits all requests of IP before authentication, so principal which is
+// guessed by tokens or API keys cannot use limit of its own. Should be
+// added before AuthorizedUser. Headers of limit are set by RateLimit
+func (mw *Middleware) RateLimitIP(w http.ResponseWriter, r *http.Request) bool {
+	result, ok := mw.limiter.AllowIP(r.Context(), utils.ClientIP(r))
+	if !ok || result.Allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", seconds(result.RetryAfter))
+	return mw.deny(w, r, ratelimit.ErrRateLimited)
+}
+
+// Requests are counted by principal if policy requires it and request is
+// authorized, otherwise by IP
+func rateLimitKey(r *http.Request, policy config.RateLimitPolicy) string {
+	if policy.By == ratelimit.ByPrincipal {
+		if accessDetails, ok := auth.FromContext(r.Context()); ok {
+			switch {
+			case accessDetails.GetAPIKeyId() != "":
+				return "api_key:" + accessDetails.GetAPIKeyId()
+			case accessDetails.GetClientId() != "":
+				return "client:" + accessDetails.GetClientId()
+			case accessDetails.GetUserId() != 0:
+				return "user:" + strconv.Itoa(accessDetails.GetUserId())
+			}
+		}
+	}
+	return "ip:" + utils.ClientIP(r)
+}
+
+// Policy in format of RateLimit-Policy header, e.g. 10;w=60;burst=3
+func rateLimitPolicy(policy config.RateLimitPolicy) string {
+	value := fmt.Sprintf("%d;w=%s", policy.Requests, seconds(policy.Window))
+	if policy.Burst > 0 {
+		value += fmt.Sprintf(";burst=%d", policy.Burst)
+	}
+	return value
+}
+
+// Headers have durations in whole seconds, so they are rounded up
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
@@ -0,0 +1,97 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Moranilt/billing/config"
+	"github.com/Moranilt/billing/logger"
+)
+
+const (
+	SenderLog     = "log"
+	SenderWebhook = "webhook"
+)
+
+type SMSSender interface {
+	// Send text message to phone number
+	SendSMS(ctx context.Context, phone string, message string) error
+}
+
+// SMSSender by name from config
+func NewSMSSender(settings config.Notifier, logger logger.LoggerWriter) (SMSSender, error) {
+	switch settings.Sender {
+	case SenderLog:
+		return NewLogSMSSender(logger, settings.Development), nil
+	case SenderWebhook:
+		return NewWebhookSMSSender(settings), nil
+	}
+	return nil, fmt.Errorf("unknown notifier sender %q", settings.Sender)
+}
+
+type logSMSSender struct {
+	logger      logger.LoggerWriter
+	development bool
+}
+
+// SMSSender which writes phone to info log instead of sending message.
+// Message contains one-time codes, so it is written only in development
+// mode. Should be used only for development and tests
+func NewLogSMSSender(logger logger.LoggerWriter, development bool) SMSSender {
+	return &logSMSSender{logger: logger, development: development}
+}
+
+func (s *logSMSSender) SendSMS(ctx context.Context, phone string, message string) error {
+	fields := []logger.Field{logger.String("phone", phone)}
+	if s.development {
+		fields = append(fields, logger.String("message", message))
+	}
+	s.logger.WithContext(ctx).Info("SMS is sent", fields...)
+	return nil
+}
+
+type Recipient struct {
+	Email string
+	Phone string
+}
+
+type Notifier interface {
+	// Deliver message to recipient by any available channel
+	Notify(ctx context.Context, recipient Recipient, subject string, message string) error
+}
+
+// Notifier by name from config
+func NewNotifier(settings config.Notifier, logger logger.LoggerWriter) (Notifier, error) {
+	switch settings.Sender {
+	case SenderLog:
+		return NewLogNotifier(logger, settings.Development), nil
+	case SenderWebhook:
+		return NewWebhookNotifier(settings), nil
+	}
+	return nil, fmt.Errorf("unknown notifier sender %q", settings.Sender)
+}
+
+type logNotifier struct {
+	logger      logger.LoggerWriter
+	development bool
+}
+
+// Notifier which writes recipient and subject to info log instead of
+// delivering message. Message contains tokens, so it is written only in
+// development mode. Should be used only for development and tests
+func NewLogNotifier(logger logger.LoggerWriter, development bool) Notifier {
+	return &logNotifier{logger: logger, development: development}
+}
+
+func (n *logNotifier) Notify(ctx context.Context, recipient Recipient, subject string, message string) error {
+	fields := []logger.Field{
+		logger.String("email", recipient.Email),
+		logger.String("phone", recipient.Phone),
+		logger.String("subject", subject),
+	}
+	if n.development {
+		fields = append(fields, logger.String("message", message))
+	}
+	n.logger.WithContext(ctx).Info("notification is sent", fields...)
+	return nil
+}
@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Moranilt/billing/logger"
+)
+
+// Logger which keeps fields of info records
+type testLogger struct {
+	logger.LoggerWriter
+	records [][]logger.Field
+}
+
+func (l *testLogger) Info(message string, fields ...logger.Field) {
+	l.records = append(l.records, fields)
+}
+
+func (l *testLogger) WithContext(ctx context.Context) logger.LoggerWriter {
+	return l
+}
+
+// Value of field in the last record
+func (l *testLogger) field(t *testing.T, key string) (any, bool) {
+	if len(l.records) == 0 {
+		t.Fatal("record was not written")
+	}
+	for _, field := range l.records[len(l.records)-1] {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+func TestLogSMSSender(t *testing.T) {
+	tests := []struct {
+		name        string
+		development bool
+	}{
+		{name: "development", development: true},
+		{name: "redacted"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := &testLogger{}
+			err := NewLogSMSSender(log, test.development).SendSMS(context.Background(), "79876543210", "Your code: 123456")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if phone, _ := log.field(t, "phone"); phone != "79876543210" {
+				t.Fatalf("expected phone to be written, got %v", phone)
+			}
+			message, ok := log.field(t, "message")
+			if test.development && message != "Your code: 123456" {
+				t.Fatalf("expected message to be written, got %v", message)
+			}
+			if !test.development && ok {
+				t.Fatal("expected message not to be written")
+			}
+		})
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		development bool
+	}{
+		{name: "development", development: true},
+		{name: "redacted"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := &testLogger{}
+			recipient := Recipient{Email: "joe@mail.com", Phone: "79876543210"}
+			err := NewLogNotifier(log, test.development).Notify(context.Background(), recipient, "Password reset", "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if subject, _ := log.field(t, "subject"); subject != "Password reset" {
+				t.Fatalf("expected subject to be written, got %v", subject)
+			}
+			message, ok := log.field(t, "message")
+			if test.development && message != "token" {
+				t.Fatalf("expected message to be written, got %v", message)
+			}
+			if !test.development && ok {
+				t.Fatal("expected message not to be written")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Moranilt/billing/config"
+)
+
+const (
+	ChannelSMS          = "sms"
+	ChannelNotification = "notification"
+)
+
+// Sends messages to gateway by HTTP, gateway delivers them to recipients
+type webhookSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type webhookMessage struct {
+	Channel string `json:"channel"`
+	Email   string `json:"email,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+// SMSSender which posts messages to gateway from config
+func NewWebhookSMSSender(settings config.Notifier) SMSSender {
+	return newWebhookSender(settings)
+}
+
+// Notifier which posts messages to gateway from config, gateway chooses
+// channel by recipient
+func NewWebhookNotifier(settings config.Notifier) Notifier {
+	return newWebhookSender(settings)
+}
+
+func newWebhookSender(settings config.Notifier) *webhookSender {
+	return &webhookSender{
+		url:    settings.WebhookURL,
+		token:  settings.WebhookToken,
+		client: &http.Client{Timeout: settings.WebhookTimeout},
+	}
+}
+
+func (s *webhookSender) SendSMS(ctx context.Context, phone string, message string) error {
+	return s.send(ctx, webhookMessage{
+		Channel: ChannelSMS,
+		Phone:   phone,
+		Message: message,
+	})
+}
+
+func (s *webhookSender) Notify(ctx context.Context, recipient Recipient, subject string, message string) error {
+	return s.send(ctx, webhookMessage{
+		Channel: ChannelNotification,
+		Email:   recipient.Email,
+		Phone:   recipient.Phone,
+		Subject: subject,
+		Message: message,
+	})
+}
+
+// Error does not contain body of response, gateway can echo message
+func (s *webhookSender) send(ctx context.Context, message webhookMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("notifier gateway responded with status %d", response.StatusCode)
+	}
+	return nil
+}
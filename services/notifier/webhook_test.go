@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+)
+
+func testWebhookSettings(url string) config.Notifier {
+	return config.Notifier{
+		Sender:         SenderWebhook,
+		WebhookURL:     url,
+		WebhookToken:   "token",
+		WebhookTimeout: time.Second,
+	}
+}
+
+func TestWebhookSendsMessage(t *testing.T) {
+	var received webhookMessage
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewWebhookSMSSender(testWebhookSettings(server.URL)).SendSMS(context.Background(), "79876543210", "Your code: 123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer token" {
+		t.Fatalf("expected token in Authorization header, got %q", authorization)
+	}
+	expected := webhookMessage{Channel: ChannelSMS, Phone: "79876543210", Message: "Your code: 123456"}
+	if received != expected {
+		t.Fatalf("expected %+v, got %+v", expected, received)
+	}
+}
+
+func TestWebhookFailsOnStatusOfGateway(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(testWebhookSettings(server.URL)).Notify(context.Background(), Recipient{Email: "joe@mail.com"}, "subject", "message")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestWebhookStopsWithContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := NewWebhookNotifier(testWebhookSettings(server.URL)).Notify(ctx, Recipient{Email: "joe@mail.com"}, "subject", "message")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected request to stop with context, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package oauth
+
+import (
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+
+	CLIENT_ID_SIZE     = 16
+	CLIENT_SECRET_SIZE = 32
+
+	// Error codes of token endpoint described in RFC 6749
+	ErrorInvalidRequest       = "invalid_request"
+	ErrorInvalidClient        = "invalid_client"
+	ErrorUnsupportedGrantType = "unsupported_grant_type"
+	ErrorInvalidScope         = "invalid_scope"
+)
+
+var (
+	ErrEmptyName          = errs.Unprocessable("name_missing", "name of client was not provided")
+	ErrNotValidScope      = errs.Unprocessable("scope_not_valid", "scope is not valid")
+	ErrNotValidClient     = errs.Unauthorized("client_not_valid", "client authentication failed")
+	ErrScopeIsNotAllowed  = errs.Forbidden("scope_not_allowed", "scope is not allowed for client")
+	ErrCredentialsMissing = errs.Unauthorized("client_credentials_missing", "client credentials were not provided")
+)
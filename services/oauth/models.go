@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"fmt"
+
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/validate"
+	"github.com/lib/pq"
+)
+
+type Client struct {
+	ClientId  string         `json:"client_id" db:"client_id"`
+	Name      string         `json:"name" db:"name"`
+	Scopes    pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedAt string         `json:"created_at" db:"created_at"`
+}
+
+// Credentials are returned only once when client is created
+type ClientCredentials struct {
+	Client
+	ClientSecret string `json:"client_secret"`
+}
+
+type ClientCreate struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,max=20"`
+}
+
+// Scopes of clients are limited by list of permissions
+func (c ClientCreate) Validate(errors *validate.Errors) {
+	for i, scope := range c.Scopes {
+		if !auth.IsPermission(scope) {
+			errors.Add(fmt.Sprintf("scopes[%d]", i), "scope", ErrNotValidScope.Error())
+		}
+	}
+}
+
+// Form of token endpoint. Client can send its id and secret in
+// Authorization header by Basic scheme instead of form
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" validate:"required,oneof=client_credentials"`
+	ClientId     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// Scopes separated by space. All of allowed scopes are granted if
+	// scope is empty
+	Scope string `json:"scope,omitempty"`
+}
+
+type TokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type clientSecret struct {
+	Client
+	SecretHash string `db:"secret_hash"`
+}
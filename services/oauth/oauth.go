@@ -0,0 +1,133 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type OAuth struct {
+	db *sqlx.DB
+}
+
+type ClientsMethods interface {
+	// Register new client with allowed scopes. Secret of client is returned
+	// only once, only hash of secret is stored
+	Create(ctx context.Context, client ClientCreate) (*ClientCredentials, error)
+	// Check id and secret of client which is not revoked
+	Authenticate(ctx context.Context, clientId string, secret string) (*Client, error)
+	// Returns requested scopes if all of them are allowed for client.
+	// Returns all of allowed scopes if scope was not requested
+	GrantScopes(client *Client, scope string) ([]string, error)
+}
+
+func NewService(db *sqlx.DB) ClientsMethods {
+	return &OAuth{db: db}
+}
+
+func (o *OAuth) Create(ctx context.Context, client ClientCreate) (*ClientCredentials, error) {
+	if client.Name == "" {
+		return nil, ErrEmptyName
+	}
+	for _, scope := range client.Scopes {
+		if !auth.IsPermission(scope) {
+			return nil, ErrNotValidScope
+		}
+	}
+
+	clientId, err := randomHex(CLIENT_ID_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(CLIENT_SECRET_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := pq.StringArray(client.Scopes)
+	if scopes == nil {
+		scopes = pq.StringArray{}
+	}
+
+	var credentials ClientCredentials
+	err = o.db.QueryRowxContext(ctx, `INSERT INTO oauth_clients
+	(client_id, name, secret_hash, scopes)
+	VALUES($1, $2, $3, $4)
+	RETURNING client_id, name, scopes, created_at`, clientId, client.Name, string(hash), scopes).StructScan(&credentials.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials.ClientSecret = secret
+	return &credentials, nil
+}
+
+func (o *OAuth) Authenticate(ctx context.Context, clientId string, secret string) (*Client, error) {
+	if clientId == "" || secret == "" {
+		return nil, ErrCredentialsMissing
+	}
+
+	var client clientSecret
+	err := o.db.GetContext(ctx, &client, `SELECT client_id, name, scopes, created_at, secret_hash
+	FROM oauth_clients
+	WHERE client_id=$1 AND revoked_at IS NULL`, clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotValidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret))
+	if err != nil {
+		return nil, ErrNotValidClient
+	}
+
+	return &client.Client, nil
+}
+
+func (o *OAuth) GrantScopes(client *Client, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return client.Scopes, nil
+	}
+
+	for _, s := range requested {
+		allowed := false
+		for _, clientScope := range client.Scopes {
+			if s == clientScope {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrScopeIsNotAllowed
+		}
+	}
+
+	return requested, nil
+}
+
+func randomHex(size int) (string, error) {
+	random := make([]byte, size)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(random), nil
+}
@@ -0,0 +1,30 @@
+package otp
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	PurposeLogin  = "login"
+	PurposeUnlock = "unlock"
+
+	CODE_LENGTH  = 6
+	MAX_ATTEMPTS = 5
+
+	TTLCode        = time.Minute * 5
+	TTLSendTimeout = time.Minute
+
+	KeyPrefixCode    = "otp:"
+	KeyPrefixTimeout = "otp_timeout:"
+)
+
+var (
+	ErrCodeExpired     = errs.Unauthorized("code_expired", "code is expired or was not requested")
+	ErrNotValidCode    = errs.Unauthorized("code_not_valid", "code is not valid")
+	ErrTooManyAttempts = errs.TooManyRequests("too_many_attempts", "too many attempts")
+	ErrSendTimeout     = errs.TooManyRequests("code_send_timeout", "code was already sent, try again later")
+	ErrRedisCannotSet  = errors.New("cannot set new code")
+)
@@ -0,0 +1,10 @@
+package otp
+
+type SendRequest struct {
+	Phone string `json:"phone" validate:"required,phone"`
+}
+
+type VerifyRequest struct {
+	Phone string `json:"phone" validate:"required,phone"`
+	Code  string `json:"code" validate:"required,len=6,digits"`
+}
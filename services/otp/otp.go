@@ -0,0 +1,149 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Moranilt/billing/services/notifier"
+	"github.com/go-redis/redis/v8"
+)
+
+// Count attempt to enter code and return number of attempts with hash of
+// code. Attempt is counted only while code exists, so counter does not
+// outlive expiry of code. Code is deleted after MAX_ATTEMPTS
+var attemptScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'hash')
+if not hash then
+	return nil
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+end
+return {attempts, hash}
+`)
+
+type otpService struct {
+	redisClient *redis.Client
+	secret      string
+	sender      notifier.SMSSender
+}
+
+type OTPMethods interface {
+	// Generate one-time code, store its hash to Redis and send code to phone.
+	// Codes of different purposes do not replace each other
+	Send(ctx context.Context, purpose string, phone string) error
+	// Verify code. Every attempt is counted and code is removed after
+	// successful verification or after MAX_ATTEMPTS
+	Verify(ctx context.Context, purpose string, phone string, code string) error
+}
+
+type OTPSettings struct {
+	RedisClient *redis.Client
+	Secret      string
+	Sender      notifier.SMSSender
+}
+
+func NewService(settings OTPSettings) OTPMethods {
+	return &otpService{
+		redisClient: settings.RedisClient,
+		secret:      settings.Secret,
+		sender:      settings.Sender,
+	}
+}
+
+func (o *otpService) Send(ctx context.Context, purpose string, phone string) error {
+	key := codeKey(purpose, phone)
+
+	// Timeout is set before sending, so concurrent requests do not send
+	// several codes. It is removed if code was not sent
+	allowed, err := o.redisClient.SetNX(ctx, KeyPrefixTimeout+key, 1, TTLSendTimeout).Result()
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrSendTimeout
+	}
+
+	err = o.send(ctx, key, phone)
+	if err != nil {
+		o.redisClient.Del(ctx, KeyPrefixTimeout+key, key)
+		return err
+	}
+
+	return nil
+}
+
+func (o *otpService) send(ctx context.Context, key string, phone string) error {
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+
+	pipe := o.redisClient.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "hash", o.hashCode(key, code), "attempts", 0)
+	pipe.Expire(ctx, key, TTLCode)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return ErrRedisCannotSet
+	}
+
+	return o.sender.SendSMS(ctx, phone, fmt.Sprintf("Your code: %s. Do not tell it to anyone.", code))
+}
+
+func (o *otpService) Verify(ctx context.Context, purpose string, phone string, code string) error {
+	key := codeKey(purpose, phone)
+
+	result, err := attemptScript.Run(ctx, o.redisClient, []string{key}, MAX_ATTEMPTS).Slice()
+	if errors.Is(err, redis.Nil) {
+		return ErrCodeExpired
+	}
+	if err != nil {
+		return err
+	}
+
+	attempts, _ := result[0].(int64)
+	storedHash, _ := result[1].(string)
+	if attempts > MAX_ATTEMPTS {
+		return ErrTooManyAttempts
+	}
+
+	if !hmac.Equal([]byte(storedHash), []byte(o.hashCode(key, code))) {
+		return ErrNotValidCode
+	}
+
+	return o.redisClient.Del(ctx, key).Err()
+}
+
+// Codes are short, so they are hashed with secret to not be brute-forced
+// by anyone who can read Redis
+func (o *otpService) hashCode(key string, code string) string {
+	mac := hmac.New(sha256.New, []byte(o.secret))
+	mac.Write([]byte(key + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func codeKey(purpose string, phone string) string {
+	return KeyPrefixCode + purpose + ":" + phone
+}
+
+func generateCode() (string, error) {
+	var max int64 = 1
+	for i := 0; i < CODE_LENGTH; i++ {
+		max *= 10
+	}
+
+	value, err := rand.Int(rand.Reader, big.NewInt(max))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", CODE_LENGTH, value.Int64()), nil
+}
@@ -0,0 +1,185 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+const testPhone = "79876543210"
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+type testSender struct {
+	messages []string
+	err      error
+}
+
+func (s *testSender) SendSMS(ctx context.Context, phone string, message string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Code from the last sent message
+func (s *testSender) code(t *testing.T) string {
+	if len(s.messages) == 0 {
+		t.Fatal("message was not sent")
+	}
+	return codePattern.FindString(s.messages[len(s.messages)-1])
+}
+
+func newTestService(t *testing.T) (OTPMethods, *miniredis.Miniredis, *testSender) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	sender := &testSender{}
+	service := NewService(OTPSettings{
+		RedisClient: client,
+		Secret:      "secret",
+		Sender:      sender,
+	})
+	return service, server, sender
+}
+
+func TestVerifyCode(t *testing.T) {
+	service, server, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.Verify(ctx, PurposeLogin, testPhone, sender.code(t))
+	if err != nil {
+		t.Fatalf("expected code to be valid, got %v", err)
+	}
+
+	err = service.Verify(ctx, PurposeLogin, testPhone, sender.code(t))
+	if !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("expected code to be used once, got %v", err)
+	}
+
+	if server.Exists(codeKey(PurposeLogin, testPhone)) {
+		t.Fatal("expected code to be deleted")
+	}
+}
+
+func TestVerifyCodeOfOtherPurpose(t *testing.T) {
+	service, _, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.Verify(ctx, PurposeUnlock, testPhone, sender.code(t))
+	if !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("expected %v, got %v", ErrCodeExpired, err)
+	}
+}
+
+func TestVerifyCountsAttempts(t *testing.T) {
+	service, server, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := sender.code(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	key := codeKey(PurposeLogin, testPhone)
+	for i := 0; i < MAX_ATTEMPTS; i++ {
+		err = service.Verify(ctx, PurposeLogin, testPhone, wrong)
+		if !errors.Is(err, ErrNotValidCode) {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, ErrNotValidCode, err)
+		}
+		if server.TTL(key) <= 0 {
+			t.Fatalf("attempt %d: expected code to keep expiry", i+1)
+		}
+	}
+
+	err = service.Verify(ctx, PurposeLogin, testPhone, code)
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected %v, got %v", ErrTooManyAttempts, err)
+	}
+	if server.Exists(key) {
+		t.Fatal("expected code to be deleted after too many attempts")
+	}
+}
+
+func TestVerifyExpiredCode(t *testing.T) {
+	service, server, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(TTLCode)
+
+	err = service.Verify(ctx, PurposeLogin, testPhone, sender.code(t))
+	if !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("expected %v, got %v", ErrCodeExpired, err)
+	}
+	if server.Exists(codeKey(PurposeLogin, testPhone)) {
+		t.Fatal("expected attempt of expired code not to create key")
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	service, server, _ := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.Send(ctx, PurposeLogin, testPhone)
+	if !errors.Is(err, ErrSendTimeout) {
+		t.Fatalf("expected %v, got %v", ErrSendTimeout, err)
+	}
+
+	server.FastForward(TTLSendTimeout)
+	err = service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatalf("expected code to be sent after timeout, got %v", err)
+	}
+}
+
+func TestSendFailureRemovesTimeout(t *testing.T) {
+	service, server, sender := newTestService(t)
+	ctx := context.Background()
+
+	sender.err = errors.New("gateway is down")
+	err := service.Send(ctx, PurposeLogin, testPhone)
+	if !errors.Is(err, sender.err) {
+		t.Fatalf("expected error of sender, got %v", err)
+	}
+
+	key := codeKey(PurposeLogin, testPhone)
+	if server.Exists(KeyPrefixTimeout+key) || server.Exists(key) {
+		t.Fatal("expected timeout and code which were not sent to be deleted")
+	}
+
+	sender.err = nil
+	err = service.Send(ctx, PurposeLogin, testPhone)
+	if err != nil {
+		t.Fatalf("expected code to be sent again, got %v", err)
+	}
+}
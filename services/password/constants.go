@@ -0,0 +1,22 @@
+package password
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	TOKEN_SIZE = 32
+
+	TTLResetToken = time.Minute * 30
+
+	KeyPrefixResetToken = "password_reset:"
+	KeyPrefixResetUser  = "password_reset_user:"
+)
+
+var (
+	ErrNotValidToken  = errs.Unprocessable("reset_token_not_valid", "reset token is not valid or expired")
+	ErrRedisCannotSet = errors.New("cannot set new reset token")
+)
@@ -0,0 +1,10 @@
+package password
+
+type ForgotRequest struct {
+	Login string `json:"login" validate:"required,max=255"`
+}
+
+type ResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
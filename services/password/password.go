@@ -0,0 +1,97 @@
+package password
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type passwordService struct {
+	redisClient *redis.Client
+	secret      string
+}
+
+type PasswordMethods interface {
+	// Create token to reset password of user. Only hash of token is stored,
+	// previous token of user is revoked
+	CreateResetToken(ctx context.Context, userId int) (string, error)
+	// Get id of user by reset token. Token can be used only once
+	ConsumeResetToken(ctx context.Context, token string) (int, error)
+}
+
+type PasswordSettings struct {
+	RedisClient *redis.Client
+	Secret      string
+}
+
+func NewService(settings PasswordSettings) PasswordMethods {
+	return &passwordService{
+		redisClient: settings.RedisClient,
+		secret:      settings.Secret,
+	}
+}
+
+func (p *passwordService) CreateResetToken(ctx context.Context, userId int) (string, error) {
+	random := make([]byte, TOKEN_SIZE)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(random)
+	hash := p.hashToken(token)
+	userKey := KeyPrefixResetUser + strconv.Itoa(userId)
+
+	previousHash, err := p.redisClient.Get(ctx, userKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+
+	pipe := p.redisClient.TxPipeline()
+	if previousHash != "" {
+		pipe.Del(ctx, KeyPrefixResetToken+previousHash)
+	}
+	pipe.Set(ctx, KeyPrefixResetToken+hash, userId, TTLResetToken)
+	pipe.Set(ctx, userKey, hash, TTLResetToken)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return "", ErrRedisCannotSet
+	}
+
+	return token, nil
+}
+
+func (p *passwordService) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	if token == "" {
+		return 0, ErrNotValidToken
+	}
+
+	userIdString, err := p.redisClient.GetDel(ctx, KeyPrefixResetToken+p.hashToken(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotValidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	userId, err := strconv.Atoi(userIdString)
+	if err != nil {
+		return 0, err
+	}
+
+	p.redisClient.Del(ctx, KeyPrefixResetUser+userIdString)
+	return userId, nil
+}
+
+func (p *passwordService) hashToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
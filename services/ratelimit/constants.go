@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	KeyPrefixBucket = "rate_limit:"
+
+	ByIP        = "ip"
+	ByPrincipal = "principal"
+	// Name of bucket of default policy, which is shared by all routes
+	// which are not listed in config
+	BucketDefault = "default"
+	// Name of bucket of policy of IP, which counts all requests of IP
+	// before authentication
+	BucketIP = "*"
+
+	KeyLimitHeader     = "RateLimit-Limit"
+	KeyRemainingHeader = "RateLimit-Remaining"
+	KeyResetHeader     = "RateLimit-Reset"
+	KeyPolicyHeader    = "RateLimit-Policy"
+
+	// Slow Redis should not delay every request
+	REDIS_TIMEOUT = time.Millisecond * 100
+	// Redis is not called for this time after it failed, requests are
+	// counted in memory meanwhile
+	REDIS_RETRY_INTERVAL = time.Second * 5
+	// Buckets in memory which are full again are removed with this interval
+	MEMORY_SWEEP_INTERVAL = time.Minute
+)
+
+var ErrRateLimited = errs.TooManyRequests("rate_limited", "too many requests, try again later")
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Buckets of one replica which are used while Redis is not available
+type memoryBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	sweptAt time.Time
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// Bucket is full again and can be removed after this time
+	fullAt time.Time
+}
+
+func newMemoryBuckets() *memoryBuckets {
+	return &memoryBuckets{
+		buckets: make(map[string]*memoryBucket),
+		sweptAt: time.Now(),
+	}
+}
+
+// Same token bucket as script of Redis
+func (m *memoryBuckets) take(key string, capacity int, rate float64, now time.Time) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(capacity), updatedAt: now}
+		m.buckets[key] = bucket
+	}
+
+	elapsed := float64(now.Sub(bucket.updatedAt).Milliseconds())
+	tokens := math.Min(float64(capacity), bucket.tokens+math.Max(0, elapsed)*rate)
+
+	result := Result{Limit: capacity}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = milliseconds((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = milliseconds((float64(capacity) - tokens) / rate)
+
+	bucket.tokens = tokens
+	bucket.updatedAt = now
+	bucket.fullAt = now.Add(result.Reset)
+	return result
+}
+
+// Full buckets are the same as missing ones, so they are removed to free
+// memory
+func (m *memoryBuckets) sweep(now time.Time) {
+	if now.Sub(m.sweptAt) < MEMORY_SWEEP_INTERVAL {
+		return
+	}
+
+	for key, bucket := range m.buckets {
+		if now.After(bucket.fullAt) {
+			delete(m.buckets, key)
+		}
+	}
+	m.sweptAt = now
+}
+
+func milliseconds(value float64) time.Duration {
+	return time.Duration(math.Ceil(value)) * time.Millisecond
+}
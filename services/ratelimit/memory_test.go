@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// 1 token per second
+const testRate = 0.001
+
+func TestMemoryBucketBurstAndRetryAfter(t *testing.T) {
+	buckets := newMemoryBuckets()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		result := buckets.take("key", 3, testRate, now)
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: expected to be allowed with %d remaining, got %+v", i+1, 2-i, result)
+		}
+	}
+
+	result := buckets.take("key", 3, testRate, now)
+	if result.Allowed {
+		t.Fatal("request after burst should be denied")
+	}
+	if result.RetryAfter != time.Second || result.Reset != time.Second*3 || result.Limit != 3 {
+		t.Fatalf("expected retry after 1s and reset after 3s, got %+v", result)
+	}
+
+	if !buckets.take("other", 3, testRate, now).Allowed {
+		t.Fatal("request of other key should be allowed")
+	}
+}
+
+func TestMemoryBucketRefill(t *testing.T) {
+	buckets := newMemoryBuckets()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		buckets.take("key", 3, testRate, now)
+	}
+
+	result := buckets.take("key", 3, testRate, now.Add(time.Millisecond*500))
+	if result.Allowed || result.RetryAfter != time.Millisecond*500 {
+		t.Fatalf("expected retry after 500ms, got %+v", result)
+	}
+	if !buckets.take("key", 3, testRate, now.Add(time.Second)).Allowed {
+		t.Fatal("request should be allowed after one token is refilled")
+	}
+	if buckets.take("key", 3, testRate, now.Add(time.Second)).Allowed {
+		t.Fatal("only one token should be refilled")
+	}
+
+	// bucket is not filled over capacity
+	later := now.Add(time.Hour)
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		if buckets.take("key", 3, testRate, later).Allowed {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Fatalf("expected 3 requests to be allowed by full bucket, got %d", allowed)
+	}
+}
+
+func TestMemoryBucketsSweepFullBuckets(t *testing.T) {
+	buckets := newMemoryBuckets()
+	now := buckets.sweptAt
+	buckets.take("full", 3, testRate, now)
+	for i := 0; i < 3; i++ {
+		buckets.take("empty", 3, testRate, now.Add(MEMORY_SWEEP_INTERVAL-time.Second))
+	}
+
+	buckets.take("other", 3, testRate, now.Add(MEMORY_SWEEP_INTERVAL))
+	if _, ok := buckets.buckets["full"]; ok {
+		t.Fatal("bucket which is full again should be removed")
+	}
+	if _, ok := buckets.buckets["empty"]; !ok {
+		t.Fatal("bucket which is not full should be kept")
+	}
+}
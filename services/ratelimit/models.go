@@ -0,0 +1,15 @@
+package ratelimit
+
+import "time"
+
+// State of bucket after request was counted
+type Result struct {
+	Allowed bool
+	// Number of requests which can be sent at once
+	Limit     int
+	Remaining int
+	// Time until bucket is full again
+	Reset time.Duration
+	// Time until next request is allowed, zero if request is allowed
+	RetryAfter time.Duration
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+	"github.com/go-redis/redis/v8"
+)
+
+// Token bucket. Bucket has capacity of burst and is refilled by rate
+// tokens per millisecond, every request takes one token. Time is taken
+// from Redis, so clocks of replicas do not matter
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(bucket[1])
+local updated_at = tonumber(bucket[2])
+if tokens == nil or updated_at == nil then
+	tokens = capacity
+	updated_at = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - updated_at) * rate)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+return {allowed, math.floor(tokens), reset, retry_after}
+`)
+
+type limiterService struct {
+	redisClient *redis.Client
+	config      config.RateLimit
+	policies    map[string]config.RateLimitPolicy
+	memory      *memoryBuckets
+	onFallback  func(err error)
+	// Time of the last failure of Redis in unix nanoseconds
+	redisFailedAt atomic.Int64
+}
+
+type LimiterMethods interface {
+	// Policy of route without version of API, e.g. /login. Default policy
+	// is returned for routes which are not listed in config, false is
+	// returned if limits are disabled
+	Policy(route string) (config.RateLimitPolicy, bool)
+	// Count request of key, e.g. IP or id of user, in bucket of policy.
+	// Requests are counted in Redis, so limits are shared by replicas.
+	// When Redis is not available they are counted in memory of replica
+	Allow(ctx context.Context, policy config.RateLimitPolicy, key string) Result
+	// Count request of IP in bucket of all requests of IP. False is
+	// returned if limits are disabled
+	AllowIP(ctx context.Context, ip string) (Result, bool)
+}
+
+type LimiterSettings struct {
+	RedisClient *redis.Client
+	Config      config.RateLimit
+	// Called when Redis fails and requests start to be counted in memory
+	OnFallback func(err error)
+}
+
+func NewService(settings LimiterSettings) LimiterMethods {
+	policies := make(map[string]config.RateLimitPolicy, len(settings.Config.Routes))
+	for _, policy := range settings.Config.Routes {
+		policies[policy.Route] = policy
+	}
+
+	onFallback := settings.OnFallback
+	if onFallback == nil {
+		onFallback = func(error) {}
+	}
+
+	return &limiterService{
+		redisClient: settings.RedisClient,
+		config:      settings.Config,
+		policies:    policies,
+		memory:      newMemoryBuckets(),
+		onFallback:  onFallback,
+	}
+}
+
+func (l *limiterService) Policy(route string) (config.RateLimitPolicy, bool) {
+	if !l.config.Enabled {
+		return config.RateLimitPolicy{}, false
+	}
+
+	policy, ok := l.policies[route]
+	if !ok {
+		return l.config.Default, true
+	}
+	return policy, true
+}
+
+func (l *limiterService) Allow(ctx context.Context, policy config.RateLimitPolicy, key string) Result {
+	return l.take(ctx, KeyPrefixBucket+bucketName(policy)+":"+key, policy)
+}
+
+func (l *limiterService) AllowIP(ctx context.Context, ip string) (Result, bool) {
+	if !l.config.Enabled {
+		return Result{}, false
+	}
+
+	return l.take(ctx, KeyPrefixBucket+BucketIP+":ip:"+ip, l.config.IP), true
+}
+
+// Take token of bucket in Redis or in memory if Redis is not available
+func (l *limiterService) take(ctx context.Context, bucket string, policy config.RateLimitPolicy) Result {
+	capacity := policy.Burst
+	if capacity == 0 {
+		capacity = policy.Requests
+	}
+	rate := float64(policy.Requests) / float64(policy.Window.Milliseconds())
+
+	if time.Since(time.Unix(0, l.redisFailedAt.Load())) < REDIS_RETRY_INTERVAL {
+		return l.memory.take(bucket, capacity, rate, time.Now())
+	}
+
+	result, err := l.takeRedis(ctx, bucket, capacity, rate)
+	if err != nil {
+		// request which was cancelled by client does not mean that
+		// Redis is not available
+		if ctx.Err() == nil {
+			l.redisFailedAt.Store(time.Now().UnixNano())
+			l.onFallback(err)
+		}
+		return l.memory.take(bucket, capacity, rate, time.Now())
+	}
+
+	return result
+}
+
+func (l *limiterService) takeRedis(ctx context.Context, bucket string, capacity int, rate float64) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, REDIS_TIMEOUT)
+	defer cancel()
+
+	values, err := takeScript.Run(ctx, l.redisClient, []string{bucket}, capacity, rate).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      capacity,
+		Remaining:  int(values[1]),
+		Reset:      time.Duration(values[2]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// Requests of routes which are not listed in config are counted together
+func bucketName(policy config.RateLimitPolicy) string {
+	if policy.Route == "" {
+		return BucketDefault
+	}
+	return policy.Route
+}
@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// 1 request per second, 3 requests at once
+var testPolicy = config.RateLimitPolicy{
+	Route:    "/login",
+	By:       ByIP,
+	Requests: 10,
+	Window:   time.Second * 10,
+	Burst:    3,
+}
+
+func newTestLimiter(t *testing.T) (*limiterService, *miniredis.Miniredis, *int) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	fallbacks := 0
+	limiter := NewService(LimiterSettings{
+		RedisClient: client,
+		Config: config.RateLimit{
+			Enabled: true,
+			Default: config.RateLimitPolicy{By: ByPrincipal, Requests: 300, Window: time.Minute},
+			IP:      config.RateLimitPolicy{By: ByIP, Requests: 600, Window: time.Minute},
+			Routes:  []config.RateLimitPolicy{testPolicy},
+		},
+		OnFallback: func(err error) { fallbacks++ },
+	}).(*limiterService)
+	return limiter, server, &fallbacks
+}
+
+func TestRedisBucketBurstAndRetryAfter(t *testing.T) {
+	limiter, server, _ := newTestLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < testPolicy.Burst; i++ {
+		result := limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+		if !result.Allowed {
+			t.Fatalf("request %d should be allowed by burst", i+1)
+		}
+		if result.Limit != testPolicy.Burst || result.Remaining != testPolicy.Burst-i-1 {
+			t.Fatalf("request %d: expected limit %d and remaining %d, got %+v", i+1, testPolicy.Burst, testPolicy.Burst-i-1, result)
+		}
+	}
+
+	result := limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	if result.Allowed {
+		t.Fatal("request after burst should be denied")
+	}
+	if result.RetryAfter != time.Second || result.Reset != time.Second*3 {
+		t.Fatalf("expected retry after 1s and reset after 3s, got %+v", result)
+	}
+
+	// other keys have their own buckets
+	if !limiter.Allow(ctx, testPolicy, "ip:5.6.7.8").Allowed {
+		t.Fatal("request of other key should be allowed")
+	}
+
+	ttl := server.TTL(KeyPrefixBucket + "/login:ip:1.2.3.4")
+	if ttl <= 0 || ttl > result.Reset+time.Second {
+		t.Fatalf("bucket should expire when it is full again, got ttl %s", ttl)
+	}
+}
+
+func TestRedisBucketRefill(t *testing.T) {
+	limiter, server, _ := newTestLimiter(t)
+	ctx := context.Background()
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < testPolicy.Burst; i++ {
+		limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	}
+
+	// half of token is not enough
+	server.SetTime(start.Add(time.Millisecond * 500))
+	result := limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	if result.Allowed || result.RetryAfter != time.Millisecond*500 {
+		t.Fatalf("expected retry after 500ms, got %+v", result)
+	}
+
+	server.SetTime(start.Add(time.Second))
+	if !limiter.Allow(ctx, testPolicy, "ip:1.2.3.4").Allowed {
+		t.Fatal("request should be allowed after one token is refilled")
+	}
+	if limiter.Allow(ctx, testPolicy, "ip:1.2.3.4").Allowed {
+		t.Fatal("only one token should be refilled")
+	}
+
+	// bucket is not filled over burst
+	server.SetTime(start.Add(time.Hour))
+	allowed := 0
+	for i := 0; i < testPolicy.Burst*2; i++ {
+		if limiter.Allow(ctx, testPolicy, "ip:1.2.3.4").Allowed {
+			allowed++
+		}
+	}
+	if allowed != testPolicy.Burst {
+		t.Fatalf("expected %d requests to be allowed by full bucket, got %d", testPolicy.Burst, allowed)
+	}
+}
+
+func TestBurstDefaultsToRequests(t *testing.T) {
+	limiter, _, _ := newTestLimiter(t)
+	policy := testPolicy
+	policy.Burst = 0
+
+	allowed := 0
+	for i := 0; i < policy.Requests+1; i++ {
+		if limiter.Allow(context.Background(), policy, "ip:1.2.3.4").Allowed {
+			allowed++
+		}
+	}
+	if allowed != policy.Requests {
+		t.Fatalf("expected %d requests to be allowed, got %d", policy.Requests, allowed)
+	}
+}
+
+func TestAllowIPUsesOwnBucket(t *testing.T) {
+	limiter, server, _ := newTestLimiter(t)
+
+	result, ok := limiter.AllowIP(context.Background(), "1.2.3.4")
+	if !ok || !result.Allowed || result.Limit != 600 {
+		t.Fatalf("expected request to be counted by policy of IP, got %+v", result)
+	}
+	if !server.Exists(KeyPrefixBucket + BucketIP + ":ip:1.2.3.4") {
+		t.Fatal("bucket of IP should be stored in Redis")
+	}
+
+	limiter.config.Enabled = false
+	if _, ok := limiter.AllowIP(context.Background(), "1.2.3.4"); ok {
+		t.Fatal("disabled limits should not count requests")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	limiter, _, _ := newTestLimiter(t)
+
+	policy, ok := limiter.Policy("/login")
+	if !ok || policy.Route != "/login" {
+		t.Fatalf("expected policy of route, got %+v", policy)
+	}
+	policy, ok = limiter.Policy("/cards")
+	if !ok || policy.Route != "" || policy.Requests != 300 {
+		t.Fatalf("expected default policy, got %+v", policy)
+	}
+}
+
+func TestFallbackToMemoryAndBackToRedis(t *testing.T) {
+	limiter, server, fallbacks := newTestLimiter(t)
+	ctx := context.Background()
+	key := KeyPrefixBucket + "/login:ip:1.2.3.4"
+
+	server.Close()
+	for i := 0; i < testPolicy.Burst; i++ {
+		if !limiter.Allow(ctx, testPolicy, "ip:1.2.3.4").Allowed {
+			t.Fatalf("request %d should be allowed by bucket in memory", i+1)
+		}
+	}
+	if limiter.Allow(ctx, testPolicy, "ip:1.2.3.4").Allowed {
+		t.Fatal("bucket in memory should be limited by the same policy")
+	}
+	if *fallbacks != 1 {
+		t.Fatalf("expected one fallback until Redis is retried, got %d", *fallbacks)
+	}
+
+	// Redis is not called again until retry interval is over
+	err := server.Restart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	if server.Exists(key) {
+		t.Fatal("Redis should not be used before retry interval is over")
+	}
+
+	limiter.redisFailedAt.Store(time.Now().Add(-REDIS_RETRY_INTERVAL).UnixNano())
+	result := limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	if !server.Exists(key) {
+		t.Fatal("Redis should be used again after retry interval")
+	}
+	if !result.Allowed || result.Remaining != testPolicy.Burst-1 {
+		t.Fatalf("expected new bucket in Redis, got %+v", result)
+	}
+	if *fallbacks != 1 {
+		t.Fatalf("expected no more fallbacks, got %d", *fallbacks)
+	}
+}
+
+func TestCancelledRequestDoesNotDisableRedis(t *testing.T) {
+	limiter, server, fallbacks := newTestLimiter(t)
+	server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Allow(ctx, testPolicy, "ip:1.2.3.4")
+	if *fallbacks != 0 || limiter.redisFailedAt.Load() != 0 {
+		t.Fatal("cancelled request should not mark Redis as failed")
+	}
+}
@@ -0,0 +1,36 @@
+package twofactor
+
+import (
+	"time"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	TOTP_ISSUER = "Billing"
+	TOTP_DIGITS = 6
+	TOTP_PERIOD = time.Second * 30
+	// Number of time steps before and after current one which are accepted
+	// to tolerate clock drift of device
+	TOTP_SKEW = 1
+
+	SECRET_SIZE = 20
+
+	RECOVERY_CODES_COUNT = 10
+	RECOVERY_CODE_SIZE   = 10
+
+	KEY_PREFIX_FAILED_ATTEMPTS = "two_factor_attempts:"
+	// Codes of user are not checked after this number of failed attempts
+	// until window of the first failed attempt is over
+	MAX_FAILED_ATTEMPTS    = 5
+	FAILED_ATTEMPTS_WINDOW = time.Minute * 15
+)
+
+var (
+	ErrNotEnrolled     = errs.Conflict("two_factor_not_enrolled", "two-factor authentication is not enrolled")
+	ErrAlreadyEnabled  = errs.Conflict("two_factor_already_enabled", "two-factor authentication is already enabled")
+	ErrNotEnabled      = errs.Conflict("two_factor_not_enabled", "two-factor authentication is not enabled")
+	ErrNotValidCode    = errs.Unauthorized("code_not_valid", "code is not valid")
+	ErrCodeAlreadyUsed = errs.Unauthorized("code_already_used", "code was already used")
+	ErrTooManyAttempts = errs.TooManyRequests("too_many_code_attempts", "too many attempts to enter code, try again later")
+)
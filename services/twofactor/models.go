@@ -0,0 +1,20 @@
+package twofactor
+
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type totpSettings struct {
+	Secret       string `db:"secret"`
+	Enabled      bool   `db:"enabled"`
+	LastUsedStep int64  `db:"last_used_step"`
+}
@@ -0,0 +1,98 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generate random secret encoded in base32 without padding as it is
+// expected by authenticator applications
+func generateSecret() (string, error) {
+	secret := make([]byte, SECRET_SIZE)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(secret), nil
+}
+
+// Time step of RFC 6238 for given time
+func timeStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTP_PERIOD.Seconds())
+}
+
+// Code of TOTP_DIGITS for given counter
+func generateCode(secret string, counter int64) (string, error) {
+	return hotp(secret, counter, TOTP_DIGITS)
+}
+
+// HOTP value of RFC 4226 for given counter
+func hotp(secret string, counter int64, digits int) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	var modulo uint32 = 1
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo), nil
+}
+
+// Validate code against time steps around given time. Returns matched
+// time step, so it can be stored to prevent reuse of the same code
+func validateCode(secret string, code string, t time.Time) (int64, bool) {
+	if len(code) != TOTP_DIGITS {
+		return 0, false
+	}
+
+	current := timeStep(t)
+	for step := current - TOTP_SKEW; step <= current+TOTP_SKEW; step++ {
+		expected, err := generateCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI for QR code which is used by authenticator applications
+func provisioningURI(account string, secret string) string {
+	label := url.PathEscape(TOTP_ISSUER + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTP_ISSUER)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	params.Set("period", fmt.Sprint(int(TOTP_PERIOD.Seconds())))
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
@@ -0,0 +1,121 @@
+package twofactor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret of test vectors of RFC 6238 for SHA1
+var rfcSecret = secretEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTPMatchesRFC6238(t *testing.T) {
+	tests := []struct {
+		time     int64
+		expected string
+	}{
+		{time: 59, expected: "94287082"},
+		{time: 1111111109, expected: "07081804"},
+		{time: 1111111111, expected: "14050471"},
+		{time: 1234567890, expected: "89005924"},
+		{time: 2000000000, expected: "69279037"},
+		{time: 20000000000, expected: "65353130"},
+	}
+
+	for _, test := range tests {
+		code, err := hotp(rfcSecret, timeStep(time.Unix(test.time, 0)), 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.expected {
+			t.Fatalf("T=%d: expected %s, got %s", test.time, test.expected, code)
+		}
+
+		// code of TOTP_DIGITS is the tail of the same value
+		code, err = generateCode(rfcSecret, timeStep(time.Unix(test.time, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.expected[len(test.expected)-TOTP_DIGITS:] {
+			t.Fatalf("T=%d: expected %s, got %s", test.time, test.expected[len(test.expected)-TOTP_DIGITS:], code)
+		}
+	}
+}
+
+func TestGenerateCodeWithLowercaseSecret(t *testing.T) {
+	upper, err := generateCode(rfcSecret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lower, err := generateCode(strings.ToLower(rfcSecret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upper != lower {
+		t.Fatalf("expected %s, got %s", upper, lower)
+	}
+}
+
+func TestGenerateCodeWithInvalidSecret(t *testing.T) {
+	_, err := generateCode("not base32!", 1)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestValidateCodeSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := timeStep(now)
+
+	tests := []struct {
+		name  string
+		step  int64
+		valid bool
+	}{
+		{name: "current step", step: current, valid: true},
+		{name: "previous step", step: current - TOTP_SKEW, valid: true},
+		{name: "next step", step: current + TOTP_SKEW, valid: true},
+		{name: "step before skew window", step: current - TOTP_SKEW - 1},
+		{name: "step after skew window", step: current + TOTP_SKEW + 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := generateCode(rfcSecret, test.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			step, ok := validateCode(rfcSecret, code, now)
+			if ok != test.valid {
+				t.Fatalf("expected valid to be %v", test.valid)
+			}
+			if ok && step != test.step {
+				t.Fatalf("expected step %d, got %d", test.step, step)
+			}
+		})
+	}
+}
+
+func TestValidateCodeWithWrongLength(t *testing.T) {
+	now := time.Unix(59, 0)
+	code, err := hotp(rfcSecret, timeStep(now), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := validateCode(rfcSecret, code, now)
+	if ok {
+		t.Fatal("expected code of wrong length to be rejected")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := provisioningURI("joe@mail.com", rfcSecret)
+	expected := "otpauth://totp/Billing:joe@mail.com?algorithm=SHA1&digits=6&issuer=Billing&period=30&secret=" + rfcSecret
+	if uri != expected {
+		t.Fatalf("expected %s, got %s", expected, uri)
+	}
+}
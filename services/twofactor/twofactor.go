@@ -0,0 +1,303 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+)
+
+type TwoFactor struct {
+	db          *sqlx.DB
+	redisClient *redis.Client
+}
+
+type TwoFactorMethods interface {
+	// Create new TOTP secret for user. Secret is not used until it is
+	// confirmed by valid code
+	Enroll(ctx context.Context, userId int) (*Enrollment, error)
+	// Enable two-factor authentication by the first valid code and
+	// generate new recovery codes
+	Confirm(ctx context.Context, userId int, code string) ([]string, error)
+	// Disable two-factor authentication. Valid code or recovery code is required
+	Disable(ctx context.Context, userId int, code string) error
+	IsEnabled(ctx context.Context, userId int) (bool, error)
+	// Verify TOTP code or one of unused recovery codes.
+	// Every code can be used only once. Failed attempts of user are
+	// counted and codes are not checked after MAX_FAILED_ATTEMPTS
+	Verify(ctx context.Context, userId int, code string) error
+}
+
+type TwoFactorSettings struct {
+	Db          *sqlx.DB
+	RedisClient *redis.Client
+}
+
+func NewService(settings TwoFactorSettings) TwoFactorMethods {
+	return &TwoFactor{
+		db:          settings.Db,
+		redisClient: settings.RedisClient,
+	}
+}
+
+func (tf *TwoFactor) Enroll(ctx context.Context, userId int) (*Enrollment, error) {
+	enabled, err := tf.IsEnabled(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	var account string
+	err = tf.db.GetContext(ctx, &account, `SELECT COALESCE(email, phone) FROM users WHERE id=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tf.db.ExecContext(ctx, `INSERT INTO user_totp (user_id, secret)
+	VALUES($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET secret=$2, enabled=FALSE, last_used_step=0, enabled_at=NULL`, userId, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		URI:    provisioningURI(account, secret),
+	}, nil
+}
+
+func (tf *TwoFactor) Confirm(ctx context.Context, userId int, code string) ([]string, error) {
+	tx, err := tf.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	settings, err := getSettings(ctx, tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	step, ok := validateCode(settings.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrNotValidCode
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE user_totp
+	SET enabled=TRUE, last_used_step=$2, enabled_at=CURRENT_TIMESTAMP
+	WHERE user_id=$1`, userId, step)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (tf *TwoFactor) Disable(ctx context.Context, userId int, code string) error {
+	err := tf.Verify(ctx, userId, code)
+	if err != nil {
+		return err
+	}
+
+	tx, err := tf.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id=$1`, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id=$1`, userId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (tf *TwoFactor) IsEnabled(ctx context.Context, userId int) (bool, error) {
+	var enabled bool
+	err := tf.db.GetContext(ctx, &enabled, `SELECT enabled FROM user_totp WHERE user_id=$1`, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+func (tf *TwoFactor) Verify(ctx context.Context, userId int, code string) error {
+	attemptsKey := KEY_PREFIX_FAILED_ATTEMPTS + strconv.Itoa(userId)
+	attempts, err := tf.redisClient.Get(ctx, attemptsKey).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if attempts >= MAX_FAILED_ATTEMPTS {
+		return ErrTooManyAttempts
+	}
+
+	err = tf.verify(ctx, userId, code)
+	if errors.Is(err, ErrNotValidCode) || errors.Is(err, ErrCodeAlreadyUsed) {
+		// window starts with the first failed attempt
+		attempts, incrErr := tf.redisClient.Incr(ctx, attemptsKey).Result()
+		if incrErr != nil {
+			return incrErr
+		}
+		if attempts == 1 {
+			tf.redisClient.Expire(ctx, attemptsKey, FAILED_ATTEMPTS_WINDOW)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	return tf.redisClient.Del(ctx, attemptsKey).Err()
+}
+
+func (tf *TwoFactor) verify(ctx context.Context, userId int, code string) error {
+	tx, err := tf.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	settings, err := getSettings(ctx, tx, userId)
+	if err != nil {
+		return err
+	}
+	if !settings.Enabled {
+		return ErrNotEnabled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == TOTP_DIGITS {
+		step, ok := validateCode(settings.Secret, code, time.Now())
+		if !ok {
+			return ErrNotValidCode
+		}
+		if step <= settings.LastUsedStep {
+			return ErrCodeAlreadyUsed
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE user_totp SET last_used_step=$2 WHERE user_id=$1`, userId, step)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE user_recovery_codes
+	SET used_at=CURRENT_TIMESTAMP
+	WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL`, userId, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotValidCode
+	}
+
+	return tx.Commit()
+}
+
+func getSettings(ctx context.Context, tx *sqlx.Tx, userId int) (*totpSettings, error) {
+	var settings totpSettings
+	err := tx.GetContext(ctx, &settings, `SELECT secret, enabled, last_used_step
+	FROM user_totp
+	WHERE user_id=$1
+	FOR UPDATE`, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userId int) ([]string, error) {
+	_, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RECOVERY_CODES_COUNT)
+	for i := 0; i < RECOVERY_CODES_COUNT; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES($1, $2)`, userId, hashRecoveryCode(normalizeCode(code)))
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// Recovery code looks like "abcde-fghij" to be easier to type
+func generateRecoveryCode() (string, error) {
+	random := make([]byte, RECOVERY_CODE_SIZE)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(secretEncoding.EncodeToString(random))[:RECOVERY_CODE_SIZE]
+	return code[:RECOVERY_CODE_SIZE/2] + "-" + code[RECOVERY_CODE_SIZE/2:], nil
+}
+
+func normalizeCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -1 +1,17 @@
 package user
+
+import (
+	"github.com/Moranilt/billing/errs"
+)
+
+const (
+	MIN_PASSWORD_LENGTH = 8
+
+	// bcrypt hash which is compared when user was not found
+	dummyPasswordHash = "$2a$10$LcmPP/YIgG//dmQeeQwi0.POKhrilUiLXttGoPz8lqHPOmdSgVnQi"
+)
+
+var (
+	ErrNotValidCredentials = errs.Unauthorized("credentials_not_valid", "login or password is not valid")
+	ErrPasswordTooShort    = errs.Unprocessable("password_too_short", "password is too short")
+)
@@ -9,6 +9,7 @@ type User struct {
 	Lastname   string       `json:"lastname" db:"lastname"`
 	Patronymic string       `json:"patronymic" db:"patronymic"`
 	Phone      string       `json:"phone" db:"phone"`
+	Role       string       `json:"role" db:"role"`
 	CreatedAt  string       `json:"created_at" db:"created_at"`
 	Cards      []cards.Card `json:"card"`
 }
@@ -20,12 +21,29 @@ type GetUser struct {
 	Lastname   string `db:"lastname"`
 	Patronymic string `db:"patronymic"`
 	Phone      string `db:"phone"`
+	Role       string `db:"role"`
 	CreatedAt  string `db:"created_at"`
 	Cards      []byte `db:"cards"`
 }
 
 type UpdateUser struct {
-	Firstname  *string
-	Lastname   *string
-	Patronymic *string
+	Firstname  *string `json:"firstname" validate:"min=1,max=100"`
+	Lastname   *string `json:"lastname" validate:"min=1,max=100"`
+	Patronymic *string `json:"patronymic" validate:"max=100"`
+}
+
+type Credentials struct {
+	Login    string `json:"login" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type userCredentials struct {
+	Id       int    `db:"id"`
+	Password string `db:"password"`
+}
+
+type Account struct {
+	Id    int    `db:"id"`
+	Email string `db:"email"`
+	Phone string `db:"phone"`
 }
@@ -1,11 +1,15 @@
 package user
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 
 	"github.com/Moranilt/billing/services/cards"
 	"github.com/Moranilt/billing/utils"
 	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
@@ -14,7 +18,15 @@ type UserService struct {
 }
 
 type UserMethods interface {
-	Get(userId int) (*User, error)
+	Get(ctx context.Context, userId int) (*User, error)
+	// Check login (email or phone) and password of user. Returns id of user
+	CheckCredentials(ctx context.Context, login string, password string) (int, error)
+	// Get id of user by phone number
+	GetIdByPhone(ctx context.Context, phone string) (int, error)
+	// Get id and contacts of user by login (email or phone)
+	GetAccount(ctx context.Context, login string) (*Account, error)
+	// Store hash of new password
+	SetPassword(ctx context.Context, userId int, password string) error
 	Delete()
 	Update(UpdateUser)
 }
@@ -26,7 +38,7 @@ func NewService(db *sqlx.DB, query utils.Query) UserMethods {
 	}
 }
 
-func (u *UserService) Get(userId int) (*User, error) {
+func (u *UserService) Get(ctx context.Context, userId int) (*User, error) {
 	var user User
 
 	var unpUser GetUser
@@ -39,12 +51,11 @@ func (u *UserService) Get(userId int) (*User, error) {
 	users.patronymic,
 	users.created_at,
 	users.phone,
-	json_agg(
+	users.role,
+	COALESCE(json_agg(
 		json_build_object(
 			'id', cards.id,
-			'number', cards.number,
 			'mask', cards.mask,
-			'cvc', cards.cvc,
 			'balance', cards.balance,
 			'until_date', cards.until_date,
 			'state', json_build_object(
@@ -52,14 +63,14 @@ func (u *UserService) Get(userId int) (*User, error) {
 				'name', cs.description
 			)
 		)
-	) as cards
+	) FILTER (WHERE cards.id IS NOT NULL), '[]') as cards
 	FROM users
-	INNER JOIN cards ON cards.user_id=users.id
-	INNER JOIN card_states as cs ON cs.id=cards.state_id
+	LEFT JOIN cards ON cards.user_id=users.id
+	LEFT JOIN card_states as cs ON cs.id=cards.state_id
 	WHERE users.id=$1
 	GROUP BY users.id`
 
-	err := u.db.Get(&unpUser, query, userId)
+	err := u.db.GetContext(ctx, &unpUser, query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +88,7 @@ func (u *UserService) Get(userId int) (*User, error) {
 		Lastname:   unpUser.Lastname,
 		Patronymic: unpUser.Patronymic,
 		Phone:      unpUser.Phone,
+		Role:       unpUser.Role,
 		CreatedAt:  unpUser.CreatedAt,
 		Cards:      cards,
 	}
@@ -84,6 +96,70 @@ func (u *UserService) Get(userId int) (*User, error) {
 	return &user, nil
 }
 
+func (u *UserService) CheckCredentials(ctx context.Context, login string, password string) (int, error) {
+	var credentials userCredentials
+	err := u.db.GetContext(ctx, &credentials, `SELECT id, password FROM users WHERE email=$1 OR phone=$1`, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		// compare anyway, so response time does not tell whether user exists
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return 0, ErrNotValidCredentials
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(credentials.Password), []byte(password))
+	if err != nil {
+		return 0, ErrNotValidCredentials
+	}
+
+	return credentials.Id, nil
+}
+
+func (u *UserService) GetIdByPhone(ctx context.Context, phone string) (int, error) {
+	var userId int
+	err := u.db.GetContext(ctx, &userId, `SELECT id FROM users WHERE phone=$1`, phone)
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (u *UserService) GetAccount(ctx context.Context, login string) (*Account, error) {
+	var account Account
+	err := u.db.GetContext(ctx, &account, `SELECT id, COALESCE(email, '') as email, phone FROM users WHERE email=$1 OR phone=$1`, login)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// Check that password satisfies requirements
+func ValidatePassword(password string) error {
+	if len(password) < MIN_PASSWORD_LENGTH {
+		return ErrPasswordTooShort
+	}
+
+	return nil
+}
+
+func (u *UserService) SetPassword(ctx context.Context, userId int, password string) error {
+	err := ValidatePassword(password)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.db.ExecContext(ctx, `UPDATE users SET password=$2 WHERE id=$1`, userId, string(hash))
+	return err
+}
+
 func (u *UserService) Delete() {
 
 }
@@ -0,0 +1,244 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Moranilt/billing/config"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Exporter by name from config. Returns nil for none
+func NewExporter(settings config.Tracing) (Exporter, error) {
+	switch settings.Exporter {
+	case ExporterNone, "":
+		return nil, nil
+	case ExporterStdout:
+		return NewStdoutExporter(os.Stdout), nil
+	case ExporterOTLP:
+		return NewOTLPExporter(settings.Endpoint, settings.ServiceName), nil
+	}
+	return nil, fmt.Errorf("unknown tracing exporter %q", settings.Exporter)
+}
+
+// Writes every span as one JSON object per line. Should be used for
+// local runs
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{w: w}
+}
+
+type stdoutSpan struct {
+	TraceId    string         `json:"trace_id"`
+	SpanId     string         `json:"span_id"`
+	ParentId   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       SpanKind       `json:"kind"`
+	Start      time.Time      `json:"start"`
+	DurationMs float64        `json:"duration_ms"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+func (e *stdoutExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		out := stdoutSpan{
+			TraceId:    span.SpanContext.TraceID.String(),
+			SpanId:     span.SpanContext.SpanID.String(),
+			Name:       span.Name,
+			Kind:       span.Kind,
+			Start:      span.Start,
+			DurationMs: float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Error:      span.Error,
+		}
+		if span.ParentSpanID.IsValid() {
+			out.ParentId = span.ParentSpanID.String()
+		}
+		if len(span.Attributes) > 0 {
+			out.Attributes = make(map[string]any, len(span.Attributes))
+			for _, attribute := range span.Attributes {
+				out.Attributes[attribute.Key] = attribute.Value
+			}
+		}
+
+		err := encoder.Encode(out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *stdoutExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Sends spans to OpenTelemetry collector by OTLP/HTTP with JSON encoding
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// Endpoint is full URL of collector, e.g. http://localhost:4318/v1/traces
+func NewOTLPExporter(endpoint string, serviceName string) Exporter {
+	return &otlpExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{},
+	}
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// Integers are strings in OTLP JSON
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+const (
+	otlpStatusUnset = 0
+	otlpStatusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: e.serviceName}}
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceId:           span.SpanContext.TraceID.String(),
+			SpanId:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusUnset},
+		}
+		if span.ParentSpanID.IsValid() {
+			out.ParentSpanId = span.ParentSpanID.String()
+		}
+		for _, attribute := range span.Attributes {
+			out.Attributes = append(out.Attributes, otlpAttributeOf(attribute.Key, attribute.Value))
+		}
+		if span.Error != "" {
+			out.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{otlpAttributeOf("service.name", e.serviceName)},
+			},
+			ScopeSpans: []otlpScopeSpans{scope},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func otlpAttributeOf(key string, value any) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case string:
+		attribute.Value.StringValue = &v
+	case int64:
+		text := strconv.FormatInt(v, 10)
+		attribute.Value.IntValue = &text
+	case bool:
+		attribute.Value.BoolValue = &v
+	default:
+		text := fmt.Sprint(v)
+		attribute.Value.StringValue = &text
+	}
+	return attribute
+}
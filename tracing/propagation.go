@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Header of W3C Trace Context
+const KeyTraceparentHeader = "traceparent"
+
+const flagSampled = 0x01
+
+// Read span context from traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func Extract(header http.Header) (SpanContext, bool) {
+	value := strings.TrimSpace(header.Get(KeyTraceparentHeader))
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four parts, future versions can add more
+	if version[0] == 0 && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if !decodeHex(parts[1], sc.TraceID[:]) || !decodeHex(parts[2], sc.SpanID[:]) {
+		return SpanContext{}, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Write span context to traceparent header
+func Inject(header http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set(KeyTraceparentHeader, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+}
+
+// Only lowercase hex of exact length is valid
+func decodeHex(value string, dst []byte) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisSpanKey struct{}
+
+// Hook of redis client which creates span for every command and
+// pipeline. Arguments of commands are not added to spans
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return startRedisSpan(ctx, cmd.Name()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx = startRedisSpan(ctx, "pipeline")
+	if span, ok := ctx.Value(redisSpanKey{}).(*Span); ok {
+		span.SetAttributes(Int("db.redis.commands", len(cmds)))
+	}
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+func startRedisSpan(ctx context.Context, command string) context.Context {
+	if !parentFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	ctx, span := Start(ctx, "redis "+command, KindClient,
+		String("db.system", "redis"),
+		String("db.operation", command),
+	)
+	return context.WithValue(ctx, redisSpanKey{}, span)
+}
+
+// Missing key is not an error of Redis
+func endRedisSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(redisSpanKey{}).(*Span)
+	if !ok {
+		return
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+)
+
+// Wrap connector of database driver to create span for every query and
+// statement which is executed with context. Values of arguments are not
+// added to spans
+func WrapConnector(connector driver.Connector) driver.Connector {
+	return &tracedConnector{connector: connector}
+}
+
+type tracedConnector struct {
+	connector driver.Connector
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn: conn}, nil
+}
+
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// Optional interfaces of connection are used if driver implements them,
+// otherwise database/sql falls back to prepared statements
+type tracedConn struct {
+	conn driver.Conn
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.conn.Prepare(query)
+}
+
+func (c *tracedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.conn.(driver.ConnBeginTx)
+	if !ok {
+		return c.Begin()
+	}
+	return beginner.BeginTx(ctx, opts)
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Spans are created only inside traced operations, so background
+// queries such as pool maintenance do not create new traces
+func startQuerySpan(ctx context.Context, query string) (context.Context, *Span) {
+	if !parentFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+
+	operation := queryOperation(query)
+	return Start(ctx, "postgres "+operation, KindClient,
+		String("db.system", "postgresql"),
+		String("db.operation", operation),
+		String("db.statement", query),
+	)
+}
+
+func endQuerySpan(span *Span, err error) {
+	if err != driver.ErrSkip {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// First keyword of query, e.g. SELECT
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// Identity of span which is propagated to children and other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Values match SpanKind of OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Finished span which is passed to exporter
+type SpanData struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	// Empty if span has not failed
+	Error string
+}
+
+// Span is created by Start and should be finished by End. Methods of nil
+// span do nothing, so code does not need to check if tracing is enabled
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+	s.mu.Unlock()
+}
+
+// Mark span as failed. Nil error is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// Finish span and pass it to exporter if it is sampled. Next calls do
+// nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Span which is stored in context by Start
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Context with span from other service, e.g. from traceparent header.
+// Spans started with this context become its children
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func parentFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+var global atomic.Value
+
+// Set tracer which is used by Start. Without tracer spans are not created
+func SetTracer(tracer *Tracer) {
+	global.Store(tracer)
+}
+
+// Start span as child of span in context. Returns context with new span.
+// Span is nil if tracer is not set
+func Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	tracer, _ := global.Load().(*Tracer)
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name, kind, attributes...)
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	parent := parentFromContext(ctx)
+
+	data := SpanData{
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: attributes,
+	}
+	if parent.IsValid() {
+		data.SpanContext.TraceID = parent.TraceID
+		data.SpanContext.Sampled = parent.Sampled
+		data.ParentSpanID = parent.SpanID
+	} else {
+		rand.Read(data.SpanContext.TraceID[:])
+		data.SpanContext.Sampled = t.sample(data.SpanContext.TraceID)
+	}
+	rand.Read(data.SpanContext.SpanID[:])
+
+	span := &Span{tracer: t, data: data}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Decision depends only on trace id, so every service makes the same
+// decision for trace which started without sampled flag
+func (t *Tracer) sample(traceId TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	value := binary.BigEndian.Uint64(traceId[8:]) >> 1
+	return float64(value) < t.sampleRatio*float64(uint64(1)<<63)
+}
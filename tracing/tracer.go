@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// Spans which wait for export. New spans are dropped when queue is full
+	QUEUE_SIZE = 2048
+	// Maximum number of spans in one export
+	BATCH_SIZE = 512
+	// Spans are exported at least this often
+	EXPORT_INTERVAL = time.Second * 5
+)
+
+type Exporter interface {
+	// Send finished spans
+	Export(ctx context.Context, spans []SpanData) error
+	// Release resources of exporter
+	Shutdown(ctx context.Context) error
+}
+
+type TracerSettings struct {
+	Exporter Exporter
+	// Part of new traces which are exported, from 0 to 1. Traces which
+	// started in other service follow their sampled flag
+	SampleRatio float64
+	// Called when export fails
+	OnError func(err error)
+}
+
+// Tracer collects finished spans and exports them in batches in background
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	onError     func(err error)
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan SpanData
+	done   chan struct{}
+}
+
+func NewTracer(settings TracerSettings) *Tracer {
+	t := &Tracer{
+		exporter:    settings.Exporter,
+		sampleRatio: settings.SampleRatio,
+		onError:     settings.OnError,
+		queue:       make(chan SpanData, QUEUE_SIZE),
+		done:        make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+
+	select {
+	case t.queue <- span:
+	default:
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(EXPORT_INTERVAL)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, BATCH_SIZE)
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				t.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= BATCH_SIZE {
+				t.export(batch)
+				batch = make([]SpanData, 0, BATCH_SIZE)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				t.export(batch)
+				batch = make([]SpanData, 0, BATCH_SIZE)
+			}
+		}
+	}
+}
+
+func (t *Tracer) export(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), EXPORT_INTERVAL)
+	defer cancel()
+	err := t.exporter.Export(ctx, batch)
+	if err != nil && t.onError != nil {
+		t.onError(err)
+	}
+}
+
+// Export spans which are in queue and shut down exporter. Spans which
+// end after Shutdown are dropped
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return t.exporter.Shutdown(ctx)
+}
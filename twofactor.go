@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/Moranilt/billing/services/auth"
+	"github.com/Moranilt/billing/services/twofactor"
+	"github.com/Moranilt/billing/validate"
+	"github.com/Moranilt/rou"
+)
+
+func (r *Repository) LoginTwoFactor(ctx *rou.Context) {
+	var body auth.PendingTokenRequest
+	err := validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	userId, err := r.Authorization.CheckPendingToken(ctx.Request().Context(), body.PendingToken)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.TwoFactor.Verify(ctx.Request().Context(), userId, body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Authorization.RevokePendingToken(ctx.Request().Context(), body.PendingToken)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	r.completeLogin(ctx, userId)
+}
+
+func (r *Repository) TwoFactorEnroll(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	enrollment, err := r.TwoFactor.Enroll(ctx.Request().Context(), accessDetails.GetUserId())
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(enrollment)
+}
+
+func (r *Repository) TwoFactorConfirm(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body twofactor.CodeRequest
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	codes, err := r.TwoFactor.Confirm(ctx.Request().Context(), accessDetails.GetUserId(), body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(twofactor.RecoveryCodes{Codes: codes})
+}
+
+func (r *Repository) TwoFactorDisable(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body twofactor.CodeRequest
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.TwoFactor.Disable(ctx.Request().Context(), accessDetails.GetUserId(), body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(nil)
+}
+
+func (r *Repository) TwoFactorStepUp(ctx *rou.Context) {
+	accessDetails, err := r.accessDetails(ctx)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	var body twofactor.CodeRequest
+	err = validate.Decode(ctx.Request(), &body)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.TwoFactor.Verify(ctx.Request().Context(), accessDetails.GetUserId(), body.Code)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	err = r.Authorization.StepUp(ctx.Request().Context(), accessDetails)
+	if err != nil {
+		r.fail(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSONResponse(auth.StepUpResponse{ExpiresIn: int(auth.TTLStepUp.Seconds())})
+}
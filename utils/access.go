@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Moranilt/billing/logger"
+)
+
+// Records status and size of response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Wraps router to assign id to every request and write access log.
+// Id is taken from X-Request-ID header of request or generated, and is
+// sent back in the same header. Context of request collects log fields,
+// so route and user id added by middlewares are written to access log
+func AccessLog(log logger.LoggerWriter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestId := r.Header.Get(KeyRequestIdHeader)
+		if !validRequestId(requestId) {
+			requestId = NewRequestId()
+		}
+		w.Header().Set(KeyRequestIdHeader, requestId)
+
+		ctx := logger.NewContext(withRequestInfo(r.Context(), requestId))
+		logger.AddFields(ctx, logger.String("request_id", requestId))
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		log.WithContext(ctx).Info("request",
+			logger.String("method", r.Method),
+			logger.String("path", r.URL.Path),
+			logger.Int("status", status),
+			logger.Any("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			logger.Int64("bytes", recorder.bytes),
+			logger.String("ip", ClientIP(r)),
+		)
+	})
+}
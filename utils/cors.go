@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Moranilt/billing/config"
+)
+
+const (
+	KeyOriginHeader  = "Origin"
+	KeyRefererHeader = "Referer"
+
+	AnyOrigin = "*"
+)
+
+// Methods and headers which web apps can use in requests to API
+var (
+	corsAllowedMethods = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+	corsAllowedHeaders = []string{
+		"Authorization",
+		"Content-Type",
+		"X-API-Key",
+		KeyRequestIdHeader,
+	}
+	// Headers of responses which are readable by web apps besides simple ones
+	corsExposedHeaders = []string{
+		KeyRequestIdHeader,
+		"Retry-After",
+		KeyDeprecationHeader,
+		KeySunsetHeader,
+		KeyLinkHeader,
+		"RateLimit-Limit",
+		"RateLimit-Remaining",
+		"RateLimit-Reset",
+		"RateLimit-Policy",
+	}
+)
+
+// Origins which are allowed to call API from browser
+type Origins []string
+
+func NewOrigins(origins []string) Origins {
+	result := make(Origins, 0, len(origins))
+	for _, origin := range origins {
+		result = append(result, normalizeOrigin(origin))
+	}
+	return result
+}
+
+// Origin is listed explicitly or any origin is allowed
+func (o Origins) Allows(origin string) bool {
+	origin = normalizeOrigin(origin)
+	for _, allowed := range o {
+		if allowed == AnyOrigin || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// Origin is listed explicitly. "*" is not trusted, so it cannot be used
+// to send requests with cookies of users
+func (o Origins) Trusts(origin string) bool {
+	origin = normalizeOrigin(origin)
+	for _, allowed := range o {
+		if allowed != AnyOrigin && allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// Wraps router to answer preflight requests and add CORS headers to
+// responses for allowed origins. Requests of other origins are passed
+// without headers, so browser does not let web app read response
+func CORS(settings config.CORS, next http.Handler) http.Handler {
+	origins := NewOrigins(settings.AllowedOrigins)
+	allowedMethods := strings.Join(corsAllowedMethods, ", ")
+	allowedHeaders := strings.Join(corsAllowedHeaders, ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(settings.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get(KeyOriginHeader)
+		if origin == "" || len(origins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// response depends on origin, caches should not mix them
+		w.Header().Add("Vary", KeyOriginHeader)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !origins.Allows(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if origins.Trusts(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if settings.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", AnyOrigin)
+		}
+
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Origin of request from Origin header. Referer is used when browser
+// does not send Origin, e.g. some of old browsers for same-origin requests
+func RequestOrigin(r *http.Request) string {
+	if origin := r.Header.Get(KeyOriginHeader); origin != "" {
+		return origin
+	}
+
+	referer, err := url.Parse(r.Header.Get(KeyRefererHeader))
+	if err != nil || referer.Scheme == "" || referer.Host == "" {
+		return ""
+	}
+	return referer.Scheme + "://" + referer.Host
+}
+
+// Origin of request is the same as host of API
+func IsSameOrigin(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return parsed.Host != "" && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// Origins are compared without trailing slash and case of scheme and host
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/rou"
+)
+
+const (
+	KeyDeprecationHeader = "Deprecation"
+	KeySunsetHeader      = "Sunset"
+	KeyLinkHeader        = "Link"
+)
+
+// Routes which are replaced by routes of newer version of API
+type Deprecation struct {
+	// Date when routes were deprecated
+	At time.Time
+	// Date after which routes can be removed
+	Sunset time.Time
+	// Prefix of routes which replace deprecated ones, e.g. /v1
+	Successor string
+}
+
+// Notify clients of deprecated routes by Deprecation (RFC 9745) and
+// Sunset (RFC 8594) headers and link to route which replaces requested
+// one. Requests are marked in logs, so clients which still use
+// deprecated routes can be found
+func Deprecated(deprecation Deprecation) rou.MiddlewareFunction {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set(KeyDeprecationHeader, fmt.Sprintf("@%d", deprecation.At.Unix()))
+		if !deprecation.Sunset.IsZero() {
+			w.Header().Set(KeySunsetHeader, deprecation.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if deprecation.Successor != "" {
+			w.Header().Add(KeyLinkHeader, fmt.Sprintf(`<%s%s>; rel="successor-version"`, deprecation.Successor, r.URL.Path))
+		}
+		logger.AddFields(r.Context(), logger.Bool("deprecated", true))
+		return true
+	}
+}
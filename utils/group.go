@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/rou"
+)
+
+type RouteGroup struct {
+	router      *rou.SimpleRouter
+	prefix      string
+	middlewares []rou.MiddlewareFunction
+	// Triggered after middlewares of route, right before handler
+	handlerMiddlewares []rou.MiddlewareFunction
+	// Shared by group and groups created from it
+	routes *[]RegisteredRoute
+}
+
+// Route which was added to router through group
+type RegisteredRoute struct {
+	Method string
+	// Pattern of router with prefix of group, e.g. /v1/cards/:id/reveal
+	Pattern string
+}
+
+// Create group of routes with common path prefix. Middlewares of group
+// are triggered before middlewares of every route in group. Group with
+// empty prefix can be used to add routes to root of router
+func NewRouteGroup(router *rou.SimpleRouter, prefix string, middlewares ...rou.MiddlewareFunction) *RouteGroup {
+	return &RouteGroup{
+		router:      router,
+		prefix:      prefix,
+		middlewares: middlewares,
+		routes:      &[]RegisteredRoute{},
+	}
+}
+
+// Create group inside of group. Prefix is added to prefix of parent and
+// middlewares of parent are triggered first
+func (g *RouteGroup) Group(prefix string, middlewares ...rou.MiddlewareFunction) *RouteGroup {
+	groupMiddlewares := make([]rou.MiddlewareFunction, 0, len(g.middlewares)+len(middlewares))
+	groupMiddlewares = append(groupMiddlewares, g.middlewares...)
+	groupMiddlewares = append(groupMiddlewares, middlewares...)
+
+	group := NewRouteGroup(g.router, g.prefix+prefix, groupMiddlewares...)
+	group.handlerMiddlewares = append(group.handlerMiddlewares, g.handlerMiddlewares...)
+	group.routes = g.routes
+	return group
+}
+
+// Routes which were added through group and groups created from it
+func (g *RouteGroup) Routes() []RegisteredRoute {
+	return append([]RegisteredRoute(nil), *g.routes...)
+}
+
+// Add middlewares which are triggered after middlewares of route, right
+// before handler, e.g. ones which need principal resolved by middlewares
+// of route. Applied to routes which are added after this call and to
+// groups which are created after it
+func (g *RouteGroup) BeforeHandler(middlewares ...rou.MiddlewareFunction) *RouteGroup {
+	g.handlerMiddlewares = append(g.handlerMiddlewares, middlewares...)
+	return g
+}
+
+func (g *RouteGroup) handler(handler func(*rou.Context)) func(*rou.Context) {
+	if len(g.handlerMiddlewares) == 0 {
+		return handler
+	}
+
+	middlewares := g.handlerMiddlewares
+	return func(ctx *rou.Context) {
+		for _, middleware := range middlewares {
+			if !middleware(ctx.ResponseWriter(), ctx.Request()) {
+				return
+			}
+		}
+		handler(ctx)
+	}
+}
+
+func (g *RouteGroup) store(method string, pattern string, route rou.RouterMethods) rou.RouterMethods {
+	*g.routes = append(*g.routes, RegisteredRoute{Method: method, Pattern: pattern})
+	route.Middleware(routeField(pattern))
+	route.Middleware(g.middlewares...)
+	return route
+}
+
+// Router does not expose matched route, so pattern is added to request
+// and its log fields before other middlewares of route
+func routeField(pattern string) rou.MiddlewareFunction {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		setRoute(r.Context(), pattern)
+		logger.AddFields(r.Context(), logger.String("route", pattern))
+		return true
+	}
+}
+
+// Writes errors of router in common response format instead of its own
+// ones, which do not have reason and id of request
+type unmatchedWriter struct {
+	http.ResponseWriter
+	request  *http.Request
+	replaced bool
+}
+
+func (w *unmatchedWriter) WriteHeader(status int) {
+	// route is set by the first middleware of every route, so response
+	// without route is written by router itself
+	if !w.replaced && Route(w.request.Context()) == "" {
+		switch status {
+		case http.StatusNotFound:
+			w.replaced = true
+			WriteError(w.ResponseWriter, w.request, errs.ErrNotFound)
+			return
+		case http.StatusMethodNotAllowed:
+			w.replaced = true
+			WriteError(w.ResponseWriter, w.request, errs.ErrNotAllowed)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *unmatchedWriter) Write(p []byte) (int, error) {
+	if w.replaced {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *unmatchedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Wraps router to respond to requests which do not match any route in
+// the same format as handlers. Should be wrapped by AccessLog, which
+// prepares context of request
+func RouterErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&unmatchedWriter{ResponseWriter: w, request: r}, r)
+	})
+}
+
+// Add route by method GET
+func (g *RouteGroup) Get(route string, handler func(*rou.Context)) rou.RouterMethods {
+	return g.store(http.MethodGet, g.prefix+route, g.router.Get(g.prefix+route, g.handler(handler)))
+}
+
+// Add route by method POST
+func (g *RouteGroup) Post(route string, handler func(*rou.Context)) rou.RouterMethods {
+	return g.store(http.MethodPost, g.prefix+route, g.router.Post(g.prefix+route, g.handler(handler)))
+}
+
+// Add route by method PUT
+func (g *RouteGroup) Put(route string, handler func(*rou.Context)) rou.RouterMethods {
+	return g.store(http.MethodPut, g.prefix+route, g.router.Put(g.prefix+route, g.handler(handler)))
+}
+
+// Add route by method PATCH
+func (g *RouteGroup) Patch(route string, handler func(*rou.Context)) rou.RouterMethods {
+	return g.store(http.MethodPatch, g.prefix+route, g.router.Patch(g.prefix+route, g.handler(handler)))
+}
+
+// Add route by method DELETE
+func (g *RouteGroup) Delete(route string, handler func(*rou.Context)) rou.RouterMethods {
+	return g.store(http.MethodDelete, g.prefix+route, g.router.Delete(g.prefix+route, g.handler(handler)))
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Moranilt/billing/metrics"
+)
+
+// Wraps router to count requests and observe their latency by route.
+// Should be wrapped by AccessLog, which prepares context of request
+func HTTPMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := Route(r.Context())
+		if route == "" {
+			route = metrics.RouteUnmatched
+		}
+
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(status))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
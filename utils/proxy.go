@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	KeyForwardedForHeader = "X-Forwarded-For"
+	KeyRealIPHeader       = "X-Real-IP"
+)
+
+type clientIPKey struct{}
+
+// Networks of load balancers and reverse proxies which are trusted to
+// report address of client
+type TrustedProxies []*net.IPNet
+
+// Values are IP addresses or CIDRs, e.g. 10.0.0.1 or 10.0.0.0/8
+func NewTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: value}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) Trusts(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Address of client. When request comes from trusted proxy, hops of
+// X-Forwarded-For are read from right to left and the first one which is
+// not trusted is the client, because hops on the left can be sent by
+// client itself. X-Real-IP is used when proxy does not send
+// X-Forwarded-For
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if len(p) == 0 || !p.Trusts(net.ParseIP(remote)) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values(KeyForwardedForHeader), ","), ",")
+	client := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		ip := net.ParseIP(hop)
+		// proxy which is trusted sent value which is not address, hops on
+		// the left of it cannot be trusted either
+		if ip == nil {
+			return client
+		}
+		client = ip.String()
+		if !p.Trusts(ip) {
+			return client
+		}
+	}
+	if client != remote {
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get(KeyRealIPHeader))); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// Wraps router to resolve address of client once for every request, so
+// ClientIP returns the same address to logs, limits and lockouts
+func RealIP(proxies TrustedProxies, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, proxies.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
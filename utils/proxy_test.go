@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := NewTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		proxies      TrustedProxies
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		expected     string
+	}{
+		{
+			name:       "direct connection",
+			proxies:    proxies,
+			remoteAddr: "203.0.113.5:1234",
+			expected:   "203.0.113.5",
+		},
+		{
+			name:         "forwarded header from client which is not proxy",
+			proxies:      proxies,
+			remoteAddr:   "203.0.113.5:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "no trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "10.0.0.1",
+		},
+		{
+			name:         "one proxy",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "hop sent by client is ignored",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.7"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "chain of proxies",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.7", "192.168.1.1, 10.2.3.4"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "every hop is proxy",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"10.1.1.1, 10.2.2.2"},
+			expected:     "10.1.1.1",
+		},
+		{
+			name:         "hop which is not address",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.7, unknown, 10.2.2.2"},
+			expected:     "10.2.2.2",
+		},
+		{
+			name:       "real IP header",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "198.51.100.7",
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "real IP header which is not address",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "unknown",
+			expected:   "10.0.0.1",
+		},
+		{
+			name:         "IPv6",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"2001:db8::1"},
+			expected:     "2001:db8::1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				r.Header.Add(KeyForwardedForHeader, value)
+			}
+			if test.realIP != "" {
+				r.Header.Set(KeyRealIPHeader, test.realIP)
+			}
+
+			ip := test.proxies.ClientIP(r)
+			if ip != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, ip)
+			}
+		})
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	proxies, err := NewTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ip string
+	handler := RealIP(proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set(KeyForwardedForHeader, "198.51.100.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if ip != "198.51.100.7" {
+		t.Fatalf("expected address from X-Forwarded-For, got %s", ip)
+	}
+}
+
+func TestNewTrustedProxiesWithInvalidValue(t *testing.T) {
+	_, err := NewTrustedProxies([]string{"proxy"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/rou"
+	"github.com/google/uuid"
+)
+
+const (
+	KeyRequestIdHeader = "X-Request-ID"
+
+	// Longer ids from clients are replaced by new ones
+	MAX_REQUEST_ID_LENGTH = 128
+)
+
+type requestInfoKey struct{}
+
+// Information about request which is collected while it goes through
+// router. Router does not expose matched route, so route is set by
+// middleware of RouteGroup
+type requestInfo struct {
+	id    string
+	mu    sync.Mutex
+	route string
+}
+
+// Returns IP address of client which made request. Address which was
+// resolved by RealIP is used, otherwise address of connection
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// Route param which should be UUID. Resources with ids which are not
+// valid cannot exist, so errs.ErrNotFound is returned for them
+func UUIDParam(ctx *rou.Context, key string) (string, error) {
+	value := ctx.RouterParams().Get(key)
+	if _, err := uuid.Parse(value); err != nil {
+		return "", errs.ErrNotFound
+	}
+	return value, nil
+}
+
+// Same as UUIDParam for numeric ids
+func IntParam(ctx *rou.Context, key string) (int, error) {
+	value, err := strconv.Atoi(ctx.RouterParams().Get(key))
+	if err != nil {
+		return 0, errs.ErrNotFound
+	}
+	return value, nil
+}
+
+func NewRequestId() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+// Id of request assigned by AccessLog
+func RequestId(ctx context.Context) string {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return ""
+	}
+	return info.id
+}
+
+// Pattern of route which matched request, e.g. /cards/:id/reveal.
+// Empty if request did not match any route of RouteGroup
+func Route(ctx context.Context) string {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return ""
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.route
+}
+
+func withRequestInfo(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: id})
+}
+
+func setRoute(ctx context.Context, route string) {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return
+	}
+	info.mu.Lock()
+	info.route = route
+	info.mu.Unlock()
+}
+
+// Id from header is used only if it is short and contains only printable
+// ASCII characters, so it is safe to write it to logs and headers
+func validRequestId(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
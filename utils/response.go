@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Moranilt/billing/errs"
+	"github.com/Moranilt/rou"
+)
+
+// Same as error object of rou with stable reason of error, which clients
+// should use to handle it, and id of request, so support can find logs
+// of request which customer reports
+type ErrorObject struct {
+	Message   string `json:"message"`
+	Code      int    `json:"code"`
+	Reason    string `json:"reason,omitempty"`
+	Details   any    `json:"details,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+type ResponseObject[T any] struct {
+	Error *ErrorObject `json:"error"`
+	Body  T            `json:"body"`
+}
+
+// Write error in common response format with id of request. Status and
+// reason are taken from *errs.Error, details of other errors are hidden
+func ErrorResponse(ctx *rou.Context, err error) {
+	WriteError(ctx.ResponseWriter(), ctx.Request(), err)
+}
+
+// Same as ErrorResponse, can be used in middlewares
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := errs.Resolve(err)
+	WriteJSON(w, e.Status, ResponseObject[any]{
+		Error: NewErrorObject(r, e),
+	})
+}
+
+func NewErrorObject(r *http.Request, err *errs.Error) *ErrorObject {
+	return &ErrorObject{
+		Message:   err.Message,
+		Code:      err.Status,
+		Reason:    err.Code,
+		Details:   err.Details,
+		RequestId: RequestId(r.Context()),
+	}
+}
+
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
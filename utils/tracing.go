@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Moranilt/billing/logger"
+	"github.com/Moranilt/billing/tracing"
+)
+
+// Wraps router to create server span for every request. Parent span is
+// taken from traceparent header. Ids of trace and span are added to log
+// fields, so it should be wrapped by AccessLog
+func HTTPTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, ok := tracing.Extract(r.Header); ok {
+			ctx = tracing.ContextWithRemoteSpanContext(ctx, parent)
+		}
+
+		ctx, span := tracing.Start(ctx, r.Method, tracing.KindServer,
+			tracing.String("http.method", r.Method),
+			tracing.String("http.target", r.URL.Path),
+			tracing.String("net.peer.ip", ClientIP(r)),
+		)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		logger.AddFields(ctx,
+			logger.String("trace_id", span.SpanContext().TraceID.String()),
+			logger.String("span_id", span.SpanContext().SpanID.String()),
+		)
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if route := Route(ctx); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(tracing.String("http.route", route))
+		}
+		span.SetAttributes(tracing.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(status)))
+		}
+	})
+}
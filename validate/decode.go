@@ -0,0 +1,65 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+// Bodies of requests are small, larger bodies are rejected before decoding
+const MAX_BODY_SIZE = 1 << 20
+
+// Decode JSON body of request to v and check it by Struct. Unknown fields
+// and bodies larger than MAX_BODY_SIZE are rejected
+func Decode(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MAX_BODY_SIZE))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	// body should contain exactly one JSON value. Second value is read as
+	// is, so its fields are not reported as unknown fields of model
+	var extra json.RawMessage
+	err = decoder.Decode(&extra)
+	if !errors.Is(err, io.EOF) {
+		if err != nil {
+			return decodeError(err)
+		}
+		return errs.ErrBodyNotValid
+	}
+
+	return Struct(v)
+}
+
+// Errors of decoding which are caused by one field are returned as
+// errors of validation, so client sees which field is wrong
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errs.ErrBodyTooLarge
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		var fieldErrors Errors
+		fieldErrors.Add(typeError.Field, "type", "should be "+typeError.Type.String())
+		return fieldErrors.Err()
+	}
+
+	// decoder does not have typed error for unknown fields
+	if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		var fieldErrors Errors
+		fieldErrors.Add(strings.Trim(field, `"`), "unknown", "is not allowed")
+		return fieldErrors.Err()
+	}
+
+	return errs.ErrBodyNotValid
+}
@@ -0,0 +1,219 @@
+package validate
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	RuleRequired = "required"
+	// Length of string or list, value of number
+	RuleMin = "min"
+	RuleMax = "max"
+	// Exact length of string
+	RuleLen    = "len"
+	RuleEmail  = "email"
+	RulePhone  = "phone"
+	RuleDigits = "digits"
+	// Number of payment card with valid check digit
+	RuleLuhn = "luhn"
+	RuleIP   = "ip"
+	// List of allowed values separated by space, e.g. oneof=user admin
+	RuleOneOf = "oneof"
+
+	// Phones are stored with country code and without formatting,
+	// e.g. 79876543210
+	PatternPhone = `^\+?[0-9]{10,15}$`
+)
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	phonePattern = regexp.MustCompile(PatternPhone)
+)
+
+// Returns message of error or empty string if value is valid
+type rule func(value reflect.Value, param string) string
+
+var rules = map[string]rule{
+	RuleRequired: checkRequired,
+	RuleMin:      checkMin,
+	RuleMax:      checkMax,
+	RuleLen:      checkLen,
+	RuleEmail:    matchString(emailPattern, "is not valid email"),
+	RulePhone:    matchString(phonePattern, "is not valid phone number"),
+	RuleDigits:   checkDigits,
+	RuleLuhn:     checkLuhn,
+	RuleIP:       checkIP,
+	RuleOneOf:    checkOneOf,
+}
+
+// Rules which are checked for list itself instead of its elements
+func isLengthRule(name string) bool {
+	return name == RuleMin || name == RuleMax || name == RuleLen
+}
+
+func checkRequired(value reflect.Value, _ string) string {
+	if isEmpty(value) {
+		return "is required"
+	}
+	if value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" {
+		return "is required"
+	}
+	return ""
+}
+
+func checkMin(value reflect.Value, param string) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "has not valid rule " + RuleMin
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if float64(utf8.RuneCountInString(value.String())) < limit {
+			return fmt.Sprintf("should be at least %s characters long", param)
+		}
+	case reflect.Slice, reflect.Map:
+		if float64(value.Len()) < limit {
+			return fmt.Sprintf("should have at least %s items", param)
+		}
+	default:
+		if number, ok := toFloat(value); ok && number < limit {
+			return "should not be less than " + param
+		}
+	}
+	return ""
+}
+
+func checkMax(value reflect.Value, param string) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "has not valid rule " + RuleMax
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if float64(utf8.RuneCountInString(value.String())) > limit {
+			return fmt.Sprintf("should be at most %s characters long", param)
+		}
+	case reflect.Slice, reflect.Map:
+		if float64(value.Len()) > limit {
+			return fmt.Sprintf("should have at most %s items", param)
+		}
+	default:
+		if number, ok := toFloat(value); ok && number > limit {
+			return "should not be greater than " + param
+		}
+	}
+	return ""
+}
+
+func checkLen(value reflect.Value, param string) string {
+	if value.Kind() != reflect.String {
+		return ""
+	}
+	if strconv.Itoa(utf8.RuneCountInString(value.String())) != param {
+		return fmt.Sprintf("should be %s characters long", param)
+	}
+	return ""
+}
+
+func matchString(pattern *regexp.Regexp, message string) rule {
+	return func(value reflect.Value, _ string) string {
+		if value.Kind() != reflect.String {
+			return ""
+		}
+		if !pattern.MatchString(value.String()) {
+			return message
+		}
+		return ""
+	}
+}
+
+func checkDigits(value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String {
+		return ""
+	}
+	if !isDigits(value.String()) {
+		return "should contain only digits"
+	}
+	return ""
+}
+
+func checkLuhn(value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String {
+		return ""
+	}
+	number := value.String()
+	if len(number) < 12 || len(number) > 19 || !isDigits(number) {
+		return "is not valid card number"
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	if sum%10 != 0 {
+		return "is not valid card number"
+	}
+	return ""
+}
+
+func checkIP(value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String {
+		return ""
+	}
+	if net.ParseIP(value.String()) == nil {
+		if _, _, err := net.ParseCIDR(value.String()); err != nil {
+			return "is not valid IP address or network"
+		}
+	}
+	return ""
+}
+
+func checkOneOf(value reflect.Value, param string) string {
+	allowed := strings.Fields(param)
+	actual := fmt.Sprint(value.Interface())
+	for _, item := range allowed {
+		if item == actual {
+			return ""
+		}
+	}
+	return "should be one of: " + strings.Join(allowed, ", ")
+}
+
+func isDigits(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func toFloat(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
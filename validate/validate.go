@@ -0,0 +1,176 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+const KeyTag = "validate"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// All of errors of one request body, they are sent to client together
+type Errors []FieldError
+
+func (e *Errors) Add(field string, rule string, message string) {
+	*e = append(*e, FieldError{Field: field, Rule: rule, Message: message})
+}
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Field + " " + fieldError.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+// Error which should be returned to client or nil if there are no errors
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return errs.ErrValidation.WithDetails(e)
+}
+
+// Models which have rules that cannot be written in tags. Validate is
+// called after rules of tags, errors should be added to given list
+type Validator interface {
+	Validate(errors *Errors)
+}
+
+// Check fields of struct by rules of `validate` tag, e.g.
+//
+//	Login string `json:"login" validate:"required,max=255"`
+//
+// Rules are separated by comma. Empty fields and nil pointers are checked
+// only by required.
+// Fields are named as in JSON, nested structs are checked too
+func Struct(v any) error {
+	var errors Errors
+	err := checkValue(reflect.ValueOf(v), "", &errors)
+	if err != nil {
+		return err
+	}
+	return errors.Err()
+}
+
+func checkValue(value reflect.Value, prefix string, errors *Errors) error {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fieldValue := value.Field(i)
+		err := checkField(fieldValue, name, field.Tag.Get(KeyTag), errors)
+		if err != nil {
+			return err
+		}
+
+		err = checkValue(fieldValue, name+".", errors)
+		if err != nil {
+			return err
+		}
+	}
+
+	if value.CanAddr() {
+		if validator, ok := value.Addr().Interface().(Validator); ok {
+			validator.Validate(errors)
+			return nil
+		}
+	}
+	if validator, ok := value.Interface().(Validator); ok {
+		validator.Validate(errors)
+	}
+
+	return nil
+}
+
+func checkField(value reflect.Value, name string, tag string, errors *Errors) error {
+	if tag == "" {
+		return nil
+	}
+
+	// value of pointer is checked even if it is empty, nil pointer means
+	// that field was not sent
+	explicit := false
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+		explicit = true
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		ruleName, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		check, ok := rules[ruleName]
+		if !ok {
+			return fmt.Errorf("unknown validation rule %q of field %s", ruleName, name)
+		}
+
+		if ruleName == RuleRequired {
+			if message := check(value, param); message != "" {
+				errors.Add(name, ruleName, message)
+				// other rules are not checked for missing value
+				return nil
+			}
+			continue
+		}
+
+		if !explicit && isEmpty(value) {
+			continue
+		}
+
+		// rules of format are checked for every element of list
+		if value.Kind() == reflect.Slice && !isLengthRule(ruleName) {
+			for i := 0; i < value.Len(); i++ {
+				if message := check(value.Index(i), param); message != "" {
+					errors.Add(fmt.Sprintf("%s[%d]", name, i), ruleName, message)
+				}
+			}
+			continue
+		}
+
+		if message := check(value, param); message != "" {
+			errors.Add(name, ruleName, message)
+		}
+	}
+
+	return nil
+}
+
+// Name of field in JSON
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
@@ -0,0 +1,344 @@
+package validate
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Moranilt/billing/errs"
+)
+
+type ruleModel struct {
+	Login    string   `json:"login" validate:"required,max=10"`
+	Email    string   `json:"email" validate:"email"`
+	Phone    string   `json:"phone" validate:"phone"`
+	Code     string   `json:"code" validate:"len=6,digits"`
+	Card     string   `json:"card" validate:"luhn"`
+	Amount   int      `json:"amount" validate:"min=1,max=100"`
+	Role     string   `json:"role" validate:"oneof=user admin"`
+	Scopes   []string `json:"scopes" validate:"min=1,max=2"`
+	IPs      []string `json:"ips" validate:"ip"`
+	Comment  *string  `json:"comment" validate:"min=3"`
+	Passport *struct {
+		Serial int `json:"serial" validate:"required"`
+	} `json:"passport"`
+}
+
+// Model which is valid by every rule, cases change one field of it
+func validRuleModel() ruleModel {
+	return ruleModel{
+		Login:  "user",
+		Email:  "user@example.com",
+		Phone:  "+79876543210",
+		Code:   "123456",
+		Card:   "4242424242424242",
+		Amount: 50,
+		Role:   "admin",
+		Scopes: []string{"cards:read"},
+		IPs:    []string{"10.0.0.1", "192.168.0.0/16", "::1"},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	empty := ""
+	short := "ab"
+	tests := []struct {
+		name   string
+		change func(m *ruleModel)
+		errors Errors
+	}{
+		{
+			name:   "valid",
+			change: func(m *ruleModel) {},
+		},
+		{
+			name: "empty optional fields",
+			change: func(m *ruleModel) {
+				*m = ruleModel{Login: "user"}
+			},
+		},
+		{
+			name:   "missing required field",
+			change: func(m *ruleModel) { m.Login = "" },
+			errors: Errors{{Field: "login", Rule: RuleRequired, Message: "is required"}},
+		},
+		{
+			name:   "required field of spaces",
+			change: func(m *ruleModel) { m.Login = "   " },
+			errors: Errors{{Field: "login", Rule: RuleRequired, Message: "is required"}},
+		},
+		{
+			name:   "string longer than max",
+			change: func(m *ruleModel) { m.Login = "abcdefghijk" },
+			errors: Errors{{Field: "login", Rule: RuleMax, Message: "should be at most 10 characters long"}},
+		},
+		{
+			name:   "length of string is counted in characters",
+			change: func(m *ruleModel) { m.Login = "пользовате" },
+		},
+		{
+			name:   "email",
+			change: func(m *ruleModel) { m.Email = "user@example" },
+			errors: Errors{{Field: "email", Rule: RuleEmail, Message: "is not valid email"}},
+		},
+		{
+			name:   "phone without plus",
+			change: func(m *ruleModel) { m.Phone = "79876543210" },
+		},
+		{
+			name:   "phone with formatting",
+			change: func(m *ruleModel) { m.Phone = "+7 (987) 654-32-10" },
+			errors: Errors{{Field: "phone", Rule: RulePhone, Message: "is not valid phone number"}},
+		},
+		{
+			name:   "short phone",
+			change: func(m *ruleModel) { m.Phone = "123456789" },
+			errors: Errors{{Field: "phone", Rule: RulePhone, Message: "is not valid phone number"}},
+		},
+		{
+			name:   "code of wrong length",
+			change: func(m *ruleModel) { m.Code = "12345" },
+			errors: Errors{{Field: "code", Rule: RuleLen, Message: "should be 6 characters long"}},
+		},
+		{
+			name:   "code with letters",
+			change: func(m *ruleModel) { m.Code = "12345a" },
+			errors: Errors{{Field: "code", Rule: RuleDigits, Message: "should contain only digits"}},
+		},
+		{
+			name:   "card number of 19 digits",
+			change: func(m *ruleModel) { m.Card = "6011000990139424000" },
+			errors: Errors{{Field: "card", Rule: RuleLuhn, Message: "is not valid card number"}},
+		},
+		{
+			name:   "card number with wrong check digit",
+			change: func(m *ruleModel) { m.Card = "4242424242424241" },
+			errors: Errors{{Field: "card", Rule: RuleLuhn, Message: "is not valid card number"}},
+		},
+		{
+			name:   "card number with spaces",
+			change: func(m *ruleModel) { m.Card = "4242 4242 4242 4242" },
+			errors: Errors{{Field: "card", Rule: RuleLuhn, Message: "is not valid card number"}},
+		},
+		{
+			name:   "short card number with valid check digit",
+			change: func(m *ruleModel) { m.Card = "79927398713" },
+			errors: Errors{{Field: "card", Rule: RuleLuhn, Message: "is not valid card number"}},
+		},
+		{
+			name:   "card number with doubled digits over nine",
+			change: func(m *ruleModel) { m.Card = "5555555555554444" },
+		},
+		{
+			name:   "number less than min",
+			change: func(m *ruleModel) { m.Amount = -1 },
+			errors: Errors{{Field: "amount", Rule: RuleMin, Message: "should not be less than 1"}},
+		},
+		{
+			name:   "number greater than max",
+			change: func(m *ruleModel) { m.Amount = 101 },
+			errors: Errors{{Field: "amount", Rule: RuleMax, Message: "should not be greater than 100"}},
+		},
+		{
+			name:   "value which is not allowed",
+			change: func(m *ruleModel) { m.Role = "root" },
+			errors: Errors{{Field: "role", Rule: RuleOneOf, Message: "should be one of: user, admin"}},
+		},
+		{
+			name:   "list longer than max",
+			change: func(m *ruleModel) { m.Scopes = []string{"a", "b", "c"} },
+			errors: Errors{{Field: "scopes", Rule: RuleMax, Message: "should have at most 2 items"}},
+		},
+		{
+			name:   "every element of list is checked",
+			change: func(m *ruleModel) { m.IPs = []string{"10.0.0.1", "host", "10.0.0.0/33"} },
+			errors: Errors{
+				{Field: "ips[1]", Rule: RuleIP, Message: "is not valid IP address or network"},
+				{Field: "ips[2]", Rule: RuleIP, Message: "is not valid IP address or network"},
+			},
+		},
+		{
+			name:   "empty value of pointer is checked",
+			change: func(m *ruleModel) { m.Comment = &empty },
+			errors: Errors{{Field: "comment", Rule: RuleMin, Message: "should be at least 3 characters long"}},
+		},
+		{
+			name:   "value of pointer",
+			change: func(m *ruleModel) { m.Comment = &short },
+			errors: Errors{{Field: "comment", Rule: RuleMin, Message: "should be at least 3 characters long"}},
+		},
+		{
+			name: "nested struct",
+			change: func(m *ruleModel) {
+				m.Passport = &struct {
+					Serial int `json:"serial" validate:"required"`
+				}{}
+			},
+			errors: Errors{{Field: "passport.serial", Rule: RuleRequired, Message: "is required"}},
+		},
+		{
+			name: "all of errors are returned",
+			change: func(m *ruleModel) {
+				m.Email = "user"
+				m.Amount = 0
+				m.Role = "root"
+			},
+			errors: Errors{
+				{Field: "email", Rule: RuleEmail, Message: "is not valid email"},
+				{Field: "role", Rule: RuleOneOf, Message: "should be one of: user, admin"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model := validRuleModel()
+			test.change(&model)
+
+			err := Struct(&model)
+			if len(test.errors) == 0 {
+				if err != nil {
+					t.Fatalf("expected model to be valid, got %v", err)
+				}
+				return
+			}
+
+			got := fieldErrors(t, err)
+			if !reflect.DeepEqual(got, test.errors) {
+				t.Fatalf("expected errors %v, got %v", test.errors, got)
+			}
+		})
+	}
+}
+
+type validatorModel struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (m validatorModel) Validate(errors *Errors) {
+	if m.From > m.To {
+		errors.Add("to", "range", "should not be less than from")
+	}
+}
+
+func TestStructCallsValidator(t *testing.T) {
+	err := Struct(validatorModel{From: 2, To: 1})
+	expected := Errors{{Field: "to", Rule: "range", Message: "should not be less than from"}}
+	if got := fieldErrors(t, err); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected errors %v, got %v", expected, got)
+	}
+
+	err = Struct(&validatorModel{From: 1, To: 2})
+	if err != nil {
+		t.Fatalf("expected model to be valid, got %v", err)
+	}
+}
+
+func TestStructWithUnknownRule(t *testing.T) {
+	model := struct {
+		Name string `json:"name" validate:"uuid"`
+	}{Name: "name"}
+
+	err := Struct(model)
+	if err == nil || errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected error of model, got %v", err)
+	}
+}
+
+type decodedModel struct {
+	Login  string `json:"login" validate:"required"`
+	Amount int    `json:"amount"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		errors Errors
+	}{
+		{
+			name: "valid body",
+			body: `{"login":"user","amount":1}`,
+		},
+		{
+			name: "body which is not JSON",
+			body: `login`,
+			err:  errs.ErrBodyNotValid,
+		},
+		{
+			name: "empty body",
+			body: ``,
+			err:  errs.ErrBodyNotValid,
+		},
+		{
+			name: "two values",
+			body: `{"login":"user"}{"login":"admin"}`,
+			err:  errs.ErrBodyNotValid,
+		},
+		{
+			name: "value after body which is not JSON",
+			body: `{"login":"user"} login`,
+			err:  errs.ErrBodyNotValid,
+		},
+		{
+			name: "body larger than limit",
+			body: `{"login":"` + strings.Repeat("a", MAX_BODY_SIZE) + `"}`,
+			err:  errs.ErrBodyTooLarge,
+		},
+		{
+			name:   "unknown field",
+			body:   `{"login":"user","role":"admin"}`,
+			errors: Errors{{Field: "role", Rule: "unknown", Message: "is not allowed"}},
+		},
+		{
+			name:   "field of wrong type",
+			body:   `{"login":"user","amount":"1"}`,
+			errors: Errors{{Field: "amount", Rule: "type", Message: "should be int"}},
+		},
+		{
+			name:   "body is validated",
+			body:   `{"amount":1}`,
+			errors: Errors{{Field: "login", Rule: RuleRequired, Message: "is required"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+			var model decodedModel
+			err := Decode(request, &model)
+
+			switch {
+			case test.err != nil:
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+			case len(test.errors) != 0:
+				got := fieldErrors(t, err)
+				if !reflect.DeepEqual(got, test.errors) {
+					t.Fatalf("expected errors %v, got %v", test.errors, got)
+				}
+			case err != nil:
+				t.Fatalf("expected body to be valid, got %v", err)
+			}
+		})
+	}
+}
+
+// Errors of fields which are sent to client with error of validation
+func fieldErrors(t *testing.T, err error) Errors {
+	t.Helper()
+	var validationErr *errs.Error
+	if !errors.As(err, &validationErr) || !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected error of validation, got %v", err)
+	}
+	details, ok := validationErr.Details.(Errors)
+	if !ok {
+		t.Fatalf("expected errors of fields in details, got %#v", validationErr.Details)
+	}
+	return details
+}